@@ -0,0 +1,405 @@
+package httphelpers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	ErrCassetteNoMatch = errors.New("no recorded interaction matches the request")
+)
+
+const cassetteRedacted = "REDACTED"
+
+/*
+CassetteMode decides whether a CassetteClient records real traffic or
+replays a recording.
+*/
+type CassetteMode int
+
+const (
+	// CassetteReplay serves responses from the cassette without making any real requests
+	CassetteReplay CassetteMode = iota
+	// CassetteRecord sends requests through the real client and saves them to the cassette, replacing what was there
+	CassetteRecord
+)
+
+/*
+CassetteClient is an HttpClient that records requests and responses to a
+JSON cassette file, and replays them later without touching the network.
+Integration tests against third party APIs can be recorded once and then
+run offline and deterministically.
+
+Secrets are redacted before anything is written. The Authorization,
+Proxy-Authorization, Cookie, Set-Cookie and X-Api-Key headers are redacted
+by default; see WithRedactedHeaders and WithRedactedQueryParams. Requests
+are redacted the same way before they are matched, so a replayed request
+matches its recording even though the secret is no longer in the file.
+
+Each recorded interaction is replayed only once. A test that sends the
+same request twice needs two recordings of it, and any further identical
+request fails with ErrCassetteNoMatch.
+*/
+type CassetteClient struct {
+	mutex        *sync.Mutex
+	path         string
+	mode         CassetteMode
+	client       HttpClient
+	opts         *CassetteOptions
+	interactions []CassetteInteraction
+	used         []bool
+}
+
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+/*
+CassetteRequest is a recorded request. Bodies that are valid UTF-8 are
+stored as text in Body, and anything else as base64 in BodyBase64.
+*/
+type CassetteRequest struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"bodyBase64,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"bodyBase64,omitempty"`
+}
+
+type CassetteOptions struct {
+	Matcher       func(req, recorded CassetteRequest) bool
+	RedactHeaders []string
+	RedactQuery   []string
+	Redactor      func(interaction *CassetteInteraction)
+}
+
+type CassetteOption func(o *CassetteOptions)
+
+/*
+NewCassetteClient creates a client that records to, or replays from, the
+cassette at path. In record mode requests are sent through client. In
+replay mode client is not used and may be nil, and the cassette must
+already exist.
+
+	mode := httphelpers.CassetteReplay
+
+	if os.Getenv("RECORD") != "" {
+		mode = httphelpers.CassetteRecord
+	}
+
+	client, err := httphelpers.NewCassetteClient("testdata/github.json", mode, http.DefaultClient)
+*/
+func NewCassetteClient(path string, mode CassetteMode, client HttpClient, options ...CassetteOption) (*CassetteClient, error) {
+	var (
+		err  error
+		data []byte
+	)
+
+	result := &CassetteClient{
+		mutex:        &sync.Mutex{},
+		path:         path,
+		mode:         mode,
+		client:       client,
+		opts:         newCassetteOptions(options),
+		interactions: []CassetteInteraction{},
+	}
+
+	if mode == CassetteRecord {
+		return result, nil
+	}
+
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("error reading cassette '%s': %w", path, err)
+	}
+
+	if err = json.Unmarshal(data, &result.interactions); err != nil {
+		return nil, fmt.Errorf("error decoding cassette '%s': %w", path, err)
+	}
+
+	result.used = make([]bool, len(result.interactions))
+	return result, nil
+}
+
+/*
+WithCassetteMatcher replaces how replayed requests are matched to
+recorded ones. Both requests have been redacted. The default matches the
+method and URL.
+*/
+func WithCassetteMatcher(matcher func(req, recorded CassetteRequest) bool) CassetteOption {
+	return func(o *CassetteOptions) {
+		o.Matcher = matcher
+	}
+}
+
+/*
+WithRedactedHeaders redacts the values of additional request and response
+headers.
+*/
+func WithRedactedHeaders(names ...string) CassetteOption {
+	return func(o *CassetteOptions) {
+		o.RedactHeaders = append(o.RedactHeaders, names...)
+	}
+}
+
+/*
+WithRedactedQueryParams redacts the values of query parameters, such as
+"api_key", in request URLs.
+*/
+func WithRedactedQueryParams(names ...string) CassetteOption {
+	return func(o *CassetteOptions) {
+		o.RedactQuery = append(o.RedactQuery, names...)
+	}
+}
+
+/*
+WithCassetteRedactor runs a function over every interaction before it is
+saved, to remove secrets the other options cannot reach, such as tokens in
+a response body.
+*/
+func WithCassetteRedactor(redactor func(interaction *CassetteInteraction)) CassetteOption {
+	return func(o *CassetteOptions) {
+		o.Redactor = redactor
+	}
+}
+
+/*
+CassetteMatchBody matches requests on their method, URL and body. Pass it
+to WithCassetteMatcher when an API is called at the same URL with
+different bodies.
+*/
+func CassetteMatchBody(req, recorded CassetteRequest) bool {
+	return cassetteMatchMethodAndURL(req, recorded) && req.Body == recorded.Body && req.BodyBase64 == recorded.BodyBase64
+}
+
+func (c *CassetteClient) Do(req *http.Request) (*http.Response, error) {
+	var (
+		err  error
+		body []byte
+	)
+
+	if body, err = readRequestBody(req); err != nil {
+		return nil, err
+	}
+
+	if c.mode == CassetteRecord {
+		return c.record(req, body)
+	}
+
+	return c.replay(req, body)
+}
+
+func (c *CassetteClient) record(req *http.Request, requestBody []byte) (*http.Response, error) {
+	var (
+		err          error
+		response     *http.Response
+		responseBody []byte
+	)
+
+	if response, err = c.client.Do(req); err != nil {
+		return nil, err
+	}
+
+	responseBody, err = io.ReadAll(response.Body)
+	response.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading response to record: %w", err)
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := CassetteInteraction{
+		Request: c.cassetteRequest(req, requestBody),
+		Response: CassetteResponse{
+			StatusCode: response.StatusCode,
+			Header:     c.redactHeader(response.Header),
+		},
+	}
+
+	interaction.Response.Body, interaction.Response.BodyBase64 = encodeCassetteBody(responseBody)
+
+	if c.opts.Redactor != nil {
+		c.opts.Redactor(&interaction)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.interactions = append(c.interactions, interaction)
+
+	if err = c.save(); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (c *CassetteClient) replay(req *http.Request, body []byte) (*http.Response, error) {
+	incoming := c.cassetteRequest(req, body)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for index, interaction := range c.interactions {
+		if c.used[index] || !c.opts.Matcher(incoming, interaction.Request) {
+			continue
+		}
+
+		c.used[index] = true
+
+		responseBody, err := decodeCassetteBody(interaction.Response.Body, interaction.Response.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding recorded response body: %w", err)
+		}
+
+		header := http.Header{}
+		maps.Copy(header, interaction.Response.Header)
+
+		response := &MockResponse{status: interaction.Response.StatusCode, header: header, body: responseBody}
+		return response.Build(req)
+	}
+
+	return nil, fmt.Errorf("%s %s: %w", incoming.Method, incoming.URL, ErrCassetteNoMatch)
+}
+
+/*
+save writes the cassette to a temporary file and renames it into place,
+so an interrupted test run never leaves a truncated cassette behind.
+*/
+func (c *CassetteClient) save() error {
+	var (
+		err  error
+		data []byte
+		temp *os.File
+	)
+
+	if data, err = json.MarshalIndent(c.interactions, "", "  "); err != nil {
+		return fmt.Errorf("error encoding cassette: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("error creating cassette directory: %w", err)
+	}
+
+	if temp, err = os.CreateTemp(filepath.Dir(c.path), ".cassette-*"); err != nil {
+		return fmt.Errorf("error creating cassette '%s': %w", c.path, err)
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err = temp.Write(append(data, '\n')); err != nil {
+		temp.Close()
+		return fmt.Errorf("error writing cassette '%s': %w", c.path, err)
+	}
+
+	if err = temp.Close(); err != nil {
+		return fmt.Errorf("error writing cassette '%s': %w", c.path, err)
+	}
+
+	if err = os.Rename(temp.Name(), c.path); err != nil {
+		return fmt.Errorf("error writing cassette '%s': %w", c.path, err)
+	}
+
+	return nil
+}
+
+func (c *CassetteClient) cassetteRequest(req *http.Request, body []byte) CassetteRequest {
+	result := CassetteRequest{
+		Method: req.Method,
+		URL:    c.redactURL(req.URL),
+		Header: c.redactHeader(req.Header),
+	}
+
+	result.Body, result.BodyBase64 = encodeCassetteBody(body)
+	return result
+}
+
+func (c *CassetteClient) redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	result := header.Clone()
+
+	for _, name := range c.opts.RedactHeaders {
+		if values := result.Values(name); len(values) > 0 {
+			result[http.CanonicalHeaderKey(name)] = slices.Repeat([]string{cassetteRedacted}, len(values))
+		}
+	}
+
+	return result
+}
+
+func (c *CassetteClient) redactURL(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+
+	for _, name := range c.opts.RedactQuery {
+		if values, ok := query[name]; ok {
+			query[name] = slices.Repeat([]string{cassetteRedacted}, len(values))
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return u.String()
+	}
+
+	result := *u
+	result.RawQuery = query.Encode()
+
+	return result.String()
+}
+
+func newCassetteOptions(options []CassetteOption) *CassetteOptions {
+	result := &CassetteOptions{
+		Matcher:       cassetteMatchMethodAndURL,
+		RedactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+	}
+
+	for _, opt := range options {
+		opt(result)
+	}
+
+	return result
+}
+
+func cassetteMatchMethodAndURL(req, recorded CassetteRequest) bool {
+	return req.Method == recorded.Method && req.URL == recorded.URL
+}
+
+func encodeCassetteBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return "", base64.StdEncoding.EncodeToString(body)
+}
+
+func decodeCassetteBody(text, encoded string) ([]byte, error) {
+	if encoded == "" {
+		return []byte(text), nil
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
@@ -0,0 +1,169 @@
+package httphelpers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCassetteTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/users":
+			w.Header().Set("Content-Type", "application/json")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+			w.Write([]byte(`[{"name":"Adam"}]`))
+
+		case "/echo":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("echo: " + string(body)))
+
+		case "/logo.png":
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00})
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doCassetteRequest(t *testing.T, client HttpClient, method, url, body string) (int, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret-token")
+
+	response, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+
+	defer response.Body.Close()
+
+	content, _ := io.ReadAll(response.Body)
+	return response.StatusCode, string(content)
+}
+
+func TestCassetteClientRecordAndReplay(t *testing.T) {
+	server := newCassetteTestServer(t)
+	cassette := filepath.Join(t.TempDir(), "testdata", "api.json")
+
+	recorder, err := NewCassetteClient(cassette, CassetteRecord, http.DefaultClient, WithRedactedQueryParams("api_key"))
+	if err != nil {
+		t.Fatalf("NewCassetteClient failed: %v", err)
+	}
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/users?api_key=secret-key&page=1", ""},
+		{http.MethodPost, "/echo", "hello"},
+		{http.MethodGet, "/logo.png", ""},
+	}
+
+	recorded := []string{}
+
+	for _, r := range requests {
+		_, body := doCassetteRequest(t, recorder, r.method, server.URL+r.path, r.body)
+		recorded = append(recorded, body)
+	}
+
+	if recorded[1] != "echo: hello" {
+		t.Errorf("Expected the real response while recording, got '%s'", recorded[1])
+	}
+
+	data, _ := os.ReadFile(cassette)
+
+	for _, secret := range []string{"secret-token", "secret-key", "secret-session"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected '%s' to be redacted from the cassette", secret)
+		}
+	}
+
+	server.Close()
+
+	replayer, err := NewCassetteClient(cassette, CassetteReplay, nil, WithRedactedQueryParams("api_key"))
+	if err != nil {
+		t.Fatalf("NewCassetteClient failed: %v", err)
+	}
+
+	for index, r := range requests {
+		status, body := doCassetteRequest(t, replayer, r.method, server.URL+r.path, r.body)
+
+		if body != recorded[index] {
+			t.Errorf("Expected replayed body '%s', got '%s'", recorded[index], body)
+		}
+
+		if r.path == "/echo" && status != http.StatusCreated {
+			t.Errorf("Expected replayed status 201, got %d", status)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/users?api_key=secret-key&page=1", nil)
+
+	if _, err = replayer.Do(req); !errors.Is(err, ErrCassetteNoMatch) {
+		t.Errorf("Expected ErrCassetteNoMatch once the interaction was used, got %v", err)
+	}
+}
+
+func TestCassetteClientReplayMatching(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	content := `[
+		{"request": {"method": "POST", "url": "https://api.example.com/search", "body": "q=a"}, "response": {"statusCode": 200, "body": "results for a"}},
+		{"request": {"method": "POST", "url": "https://api.example.com/search", "body": "q=b"}, "response": {"statusCode": 200, "body": "results for b", "header": {"Content-Type": ["text/plain"]}}}
+	]`
+
+	if err := os.WriteFile(cassette, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write cassette: %v", err)
+	}
+
+	replayer, err := NewCassetteClient(cassette, CassetteReplay, nil, WithCassetteMatcher(CassetteMatchBody))
+	if err != nil {
+		t.Fatalf("NewCassetteClient failed: %v", err)
+	}
+
+	if _, body := doCassetteRequest(t, replayer, http.MethodPost, "https://api.example.com/search", "q=b"); body != "results for b" {
+		t.Errorf("Expected the body to select the interaction, got '%s'", body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.example.com/search", strings.NewReader("q=c"))
+
+	if _, err = replayer.Do(req); !errors.Is(err, ErrCassetteNoMatch) {
+		t.Errorf("Expected ErrCassetteNoMatch, got %v", err)
+	}
+}
+
+func TestCassetteClientRedactor(t *testing.T) {
+	server := newCassetteTestServer(t)
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, _ := NewCassetteClient(cassette, CassetteRecord, http.DefaultClient, WithCassetteRedactor(func(interaction *CassetteInteraction) {
+		interaction.Response.Body = strings.ReplaceAll(interaction.Response.Body, "Adam", "someone")
+	}))
+
+	if _, body := doCassetteRequest(t, recorder, http.MethodGet, server.URL+"/users", ""); body != `[{"name":"Adam"}]` {
+		t.Errorf("Expected the caller to get the real response, got '%s'", body)
+	}
+
+	data, _ := os.ReadFile(cassette)
+
+	if strings.Contains(string(data), "Adam") || !strings.Contains(string(data), "someone") {
+		t.Errorf("Expected the redactor to run before saving, got %s", data)
+	}
+}
+
+func TestCassetteClientMissingCassette(t *testing.T) {
+	if _, err := NewCassetteClient(filepath.Join(t.TempDir(), "missing.json"), CassetteReplay, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+}
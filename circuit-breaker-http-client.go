@@ -0,0 +1,430 @@
+package httphelpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+/*
+circuitBuckets is how many buckets the rolling window is divided into.
+Old results leave the window one bucket at a time.
+*/
+const circuitBuckets = 10
+
+/*
+CircuitState is the state of one circuit in a CircuitBreakerClient.
+*/
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through while counting failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests with ErrCircuitOpen without sending them
+	CircuitOpen
+	// CircuitHalfOpen lets a few trial requests through to decide whether to close again
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+
+	case CircuitOpen:
+		return "open"
+
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+/*
+CircuitOpenError is returned for requests refused by an open circuit. Key
+is the circuit, by default the request's host, and RetryAt is when the
+circuit will let a trial request through. It matches ErrCircuitOpen with
+errors.Is.
+*/
+type CircuitOpenError struct {
+	Key     string
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for '%s' is open until %s", e.Key, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+/*
+CircuitBreakerClient is an HttpClient that stops sending requests to a
+dependency that is failing, giving it time to recover instead of adding
+to its load.
+
+Each key, by default each host, has its own circuit. A closed circuit
+sends requests and counts failures over a rolling window. When enough of
+them fail the circuit opens, and requests fail at once with a
+*CircuitOpenError. After the open timeout the circuit is half-open and
+lets a few trial requests through: if they all succeed it closes, and if
+any fails it opens again.
+*/
+type CircuitBreakerClient struct {
+	mutex    *sync.Mutex
+	client   HttpClient
+	opts     *CircuitBreakerOptions
+	now      func() time.Time
+	circuits map[string]*circuitBreaker
+}
+
+type CircuitBreakerOptions struct {
+	Key              func(req *http.Request) string
+	IsFailure        func(response *http.Response, err error) bool
+	FailureRate      float64
+	MinRequests      int
+	Window           time.Duration
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+	OnStateChange    func(key string, from, to CircuitState)
+}
+
+type CircuitBreakerOption func(o *CircuitBreakerOptions)
+
+type circuitBreaker struct {
+	state      CircuitState
+	generation int
+	openedAt   time.Time
+	buckets    [circuitBuckets]circuitBucket
+	inFlight   int
+	successes  int
+}
+
+type circuitBucket struct {
+	index    int64
+	requests int
+	failures int
+}
+
+type circuitStateChange struct {
+	key      string
+	from, to CircuitState
+}
+
+/*
+NewCircuitBreakerClient wraps client with a circuit breaker per host. By
+default a circuit opens when at least half of the requests in the last
+minute failed, once there have been at least 20 of them. It stays open
+for 30 seconds, and then closes after one successful trial request.
+Failures are errors and 5xx responses.
+
+	client := httphelpers.NewCircuitBreakerClient(http.DefaultClient,
+		httphelpers.WithFailureRate(0.25, 10),
+		httphelpers.WithOpenTimeout(time.Minute),
+	)
+*/
+func NewCircuitBreakerClient(client HttpClient, options ...CircuitBreakerOption) *CircuitBreakerClient {
+	return &CircuitBreakerClient{
+		mutex:    &sync.Mutex{},
+		client:   client,
+		opts:     newCircuitBreakerOptions(options),
+		now:      time.Now,
+		circuits: map[string]*circuitBreaker{},
+	}
+}
+
+/*
+WithCircuitKey decides which circuit a request belongs to, such as to
+break per host and path prefix, or per API key.
+*/
+func WithCircuitKey(key func(req *http.Request) string) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.Key = key
+	}
+}
+
+/*
+WithCircuitFailure decides which results count as failures. Requests
+cancelled by the caller are never counted.
+*/
+func WithCircuitFailure(isFailure func(response *http.Response, err error) bool) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.IsFailure = isFailure
+	}
+}
+
+/*
+WithFailureRate opens a circuit when at least rate, between 0 and 1, of
+the requests in the window failed. minRequests keeps a few failures while
+traffic is light from opening the circuit.
+*/
+func WithFailureRate(rate float64, minRequests int) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.FailureRate = rate
+		o.MinRequests = minRequests
+	}
+}
+
+/*
+WithCircuitWindow sets how far back requests are counted.
+*/
+func WithCircuitWindow(window time.Duration) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.Window = window
+	}
+}
+
+/*
+WithOpenTimeout sets how long a circuit stays open before trial requests
+are let through.
+*/
+func WithOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.OpenTimeout = timeout
+	}
+}
+
+/*
+WithHalfOpenRequests sets how many trial requests a half-open circuit
+lets through. All of them must succeed for the circuit to close.
+*/
+func WithHalfOpenRequests(n int) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.HalfOpenRequests = n
+	}
+}
+
+/*
+WithStateChange calls onStateChange whenever a circuit changes state,
+such as to log it or update a metric. It is called after the change, and
+may call State.
+*/
+func WithStateChange(onStateChange func(key string, from, to CircuitState)) CircuitBreakerOption {
+	return func(o *CircuitBreakerOptions) {
+		o.OnStateChange = onStateChange
+	}
+}
+
+func (c *CircuitBreakerClient) Do(req *http.Request) (*http.Response, error) {
+	var (
+		err        error
+		response   *http.Response
+		generation int
+		changes    []circuitStateChange
+	)
+
+	key := c.opts.Key(req)
+
+	generation, changes, err = c.allow(key)
+	c.notify(changes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.client.Do(req)
+
+	if err != nil && req.Context().Err() != nil {
+		c.release(key, generation)
+		return response, err
+	}
+
+	c.notify(c.record(key, generation, c.opts.IsFailure(response, err)))
+	return response, err
+}
+
+/*
+State returns the state of the circuit for key. Circuits that have not
+been used are closed. An open circuit becomes half-open when a request
+arrives after the open timeout.
+*/
+func (c *CircuitBreakerClient) State(key string) CircuitState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if circuit, ok := c.circuits[key]; ok {
+		return circuit.state
+	}
+
+	return CircuitClosed
+}
+
+/*
+allow decides whether a request may be sent, returning the generation of
+the circuit it was sent in. Results from an earlier generation, such as a
+slow request that was sent before the circuit opened, are ignored.
+*/
+func (c *CircuitBreakerClient) allow(key string) (int, []circuitStateChange, error) {
+	var changes []circuitStateChange
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	circuit, ok := c.circuits[key]
+
+	if !ok {
+		circuit = &circuitBreaker{}
+		c.circuits[key] = circuit
+	}
+
+	if circuit.state == CircuitOpen {
+		retryAt := circuit.openedAt.Add(c.opts.OpenTimeout)
+
+		if c.now().Before(retryAt) {
+			return 0, nil, &CircuitOpenError{Key: key, RetryAt: retryAt}
+		}
+
+		changes = append(changes, c.transition(key, circuit, CircuitHalfOpen))
+	}
+
+	if circuit.state == CircuitHalfOpen {
+		if circuit.inFlight+circuit.successes >= c.opts.HalfOpenRequests {
+			return 0, changes, &CircuitOpenError{Key: key, RetryAt: c.now()}
+		}
+
+		circuit.inFlight++
+	}
+
+	return circuit.generation, changes, nil
+}
+
+func (c *CircuitBreakerClient) record(key string, generation int, failed bool) []circuitStateChange {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	circuit := c.circuits[key]
+
+	if circuit.generation != generation {
+		return nil
+	}
+
+	if circuit.state == CircuitHalfOpen {
+		circuit.inFlight--
+
+		if failed {
+			return []circuitStateChange{c.transition(key, circuit, CircuitOpen)}
+		}
+
+		if circuit.successes++; circuit.successes >= c.opts.HalfOpenRequests {
+			return []circuitStateChange{c.transition(key, circuit, CircuitClosed)}
+		}
+
+		return nil
+	}
+
+	bucket := c.bucket(circuit)
+	bucket.requests++
+
+	if failed {
+		bucket.failures++
+	}
+
+	requests, failures := c.windowTotals(circuit)
+
+	if failed && requests >= c.opts.MinRequests && float64(failures) >= c.opts.FailureRate*float64(requests) {
+		return []circuitStateChange{c.transition(key, circuit, CircuitOpen)}
+	}
+
+	return nil
+}
+
+/*
+release frees a half-open circuit's slot for a request whose result is
+not counted.
+*/
+func (c *CircuitBreakerClient) release(key string, generation int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if circuit := c.circuits[key]; circuit.generation == generation && circuit.state == CircuitHalfOpen {
+		circuit.inFlight--
+	}
+}
+
+/*
+transition moves a circuit to a new state, starting a new generation with
+no counts. The caller must hold the mutex.
+*/
+func (c *CircuitBreakerClient) transition(key string, circuit *circuitBreaker, to CircuitState) circuitStateChange {
+	change := circuitStateChange{key: key, from: circuit.state, to: to}
+
+	*circuit = circuitBreaker{
+		state:      to,
+		generation: circuit.generation + 1,
+	}
+
+	if to == CircuitOpen {
+		circuit.openedAt = c.now()
+	}
+
+	return change
+}
+
+func (c *CircuitBreakerClient) bucket(circuit *circuitBreaker) *circuitBucket {
+	index := c.bucketIndex()
+	bucket := &circuit.buckets[index%circuitBuckets]
+
+	if bucket.index != index {
+		*bucket = circuitBucket{index: index}
+	}
+
+	return bucket
+}
+
+func (c *CircuitBreakerClient) windowTotals(circuit *circuitBreaker) (int, int) {
+	requests, failures := 0, 0
+	index := c.bucketIndex()
+
+	for _, bucket := range circuit.buckets {
+		if index-bucket.index < circuitBuckets {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+
+	return requests, failures
+}
+
+func (c *CircuitBreakerClient) bucketIndex() int64 {
+	return c.now().UnixNano() / max(int64(c.opts.Window/circuitBuckets), 1)
+}
+
+func (c *CircuitBreakerClient) notify(changes []circuitStateChange) {
+	if c.opts.OnStateChange == nil {
+		return
+	}
+
+	for _, change := range changes {
+		c.opts.OnStateChange(change.key, change.from, change.to)
+	}
+}
+
+func newCircuitBreakerOptions(options []CircuitBreakerOption) *CircuitBreakerOptions {
+	result := &CircuitBreakerOptions{
+		Key: func(req *http.Request) string {
+			return req.URL.Host
+		},
+		IsFailure: func(response *http.Response, err error) bool {
+			return err != nil || response.StatusCode >= http.StatusInternalServerError
+		},
+		FailureRate:      0.5,
+		MinRequests:      20,
+		Window:           time.Minute,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 1,
+	}
+
+	for _, opt := range options {
+		opt(result)
+	}
+
+	return result
+}
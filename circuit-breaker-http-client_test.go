@@ -0,0 +1,253 @@
+package httphelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+/*
+fakeHostClient answers each host with the status set for it, or an error
+when the status is 0.
+*/
+type fakeHostClient struct {
+	statuses map[string]int
+	calls    int
+}
+
+func (f *fakeHostClient) Do(req *http.Request) (*http.Response, error) {
+	f.calls++
+
+	if status := f.statuses[req.URL.Host]; status != 0 {
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	}
+
+	return nil, errors.New("connection refused")
+}
+
+type testCircuitBreaker struct {
+	*CircuitBreakerClient
+	downstream *fakeHostClient
+	clock      time.Time
+	changes    []string
+}
+
+func newTestCircuitBreaker(options ...CircuitBreakerOption) *testCircuitBreaker {
+	result := &testCircuitBreaker{
+		downstream: &fakeHostClient{statuses: map[string]int{}},
+		clock:      time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	options = append(options, WithStateChange(func(key string, from, to CircuitState) {
+		result.changes = append(result.changes, fmt.Sprintf("%s: %s -> %s", key, from, to))
+	}))
+
+	result.CircuitBreakerClient = NewCircuitBreakerClient(result.downstream, options...)
+	result.now = func() time.Time { return result.clock }
+
+	return result
+}
+
+func (b *testCircuitBreaker) send(t *testing.T, host string, count int) (int, error) {
+	t.Helper()
+
+	var (
+		err      error
+		response *http.Response
+	)
+
+	status := 0
+
+	for range count {
+		if response, err = b.Do(httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)); response != nil {
+			status = response.StatusCode
+		}
+	}
+
+	return status, err
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	breaker := newTestCircuitBreaker(WithFailureRate(0.5, 4), WithOpenTimeout(30*time.Second))
+
+	breaker.downstream.statuses["api.example.com"] = http.StatusOK
+	breaker.send(t, "api.example.com", 3)
+
+	breaker.downstream.statuses["api.example.com"] = http.StatusServiceUnavailable
+	breaker.send(t, "api.example.com", 2)
+
+	if state := breaker.State("api.example.com"); state != CircuitClosed {
+		t.Fatalf("Expected the circuit to stay closed with 2 of 5 failures, got %s", state)
+	}
+
+	breaker.send(t, "api.example.com", 1)
+
+	if state := breaker.State("api.example.com"); state != CircuitOpen {
+		t.Fatalf("Expected the circuit to open with 3 of 6 failures, got %s", state)
+	}
+
+	calls := breaker.downstream.calls
+	_, err := breaker.send(t, "api.example.com", 1)
+
+	var openErr *CircuitOpenError
+
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected a *CircuitOpenError, got %v", err)
+	}
+
+	if openErr.Key != "api.example.com" || !openErr.RetryAt.Equal(breaker.clock.Add(30*time.Second)) {
+		t.Errorf("Unexpected error %+v", openErr)
+	}
+
+	if breaker.downstream.calls != calls {
+		t.Errorf("Expected an open circuit not to send requests")
+	}
+
+	breaker.downstream.statuses["other.example.com"] = http.StatusOK
+
+	if status, err := breaker.send(t, "other.example.com", 1); status != http.StatusOK || err != nil {
+		t.Errorf("Expected other hosts to be unaffected, got %d and %v", status, err)
+	}
+
+	breaker.clock = breaker.clock.Add(30 * time.Second)
+
+	if _, err = breaker.send(t, "api.example.com", 1); err != nil {
+		t.Errorf("Expected a trial request after the open timeout, got %v", err)
+	}
+
+	if state := breaker.State("api.example.com"); state != CircuitOpen {
+		t.Fatalf("Expected a failed trial to open the circuit again, got %s", state)
+	}
+
+	breaker.clock = breaker.clock.Add(30 * time.Second)
+	breaker.downstream.statuses["api.example.com"] = http.StatusOK
+
+	if status, _ := breaker.send(t, "api.example.com", 1); status != http.StatusOK {
+		t.Errorf("Expected the trial request to succeed, got %d", status)
+	}
+
+	expected := []string{
+		"api.example.com: closed -> open",
+		"api.example.com: open -> half-open",
+		"api.example.com: half-open -> open",
+		"api.example.com: open -> half-open",
+		"api.example.com: half-open -> closed",
+	}
+
+	if fmt.Sprint(breaker.changes) != fmt.Sprint(expected) {
+		t.Errorf("Expected state changes %q, got %q", expected, breaker.changes)
+	}
+}
+
+func TestCircuitBreakerRollingWindow(t *testing.T) {
+	breaker := newTestCircuitBreaker(WithFailureRate(0.5, 4), WithCircuitWindow(10*time.Second))
+
+	breaker.send(t, "api.example.com", 3)
+
+	// The failures leave the window before enough requests are made.
+	breaker.clock = breaker.clock.Add(11 * time.Second)
+	breaker.downstream.statuses["api.example.com"] = http.StatusOK
+	breaker.send(t, "api.example.com", 3)
+
+	breaker.downstream.statuses["api.example.com"] = 0
+	breaker.send(t, "api.example.com", 2)
+
+	if state := breaker.State("api.example.com"); state != CircuitClosed {
+		t.Errorf("Expected 2 of 5 failures in the window to keep the circuit closed, got %s", state)
+	}
+
+	breaker.send(t, "api.example.com", 1)
+
+	if state := breaker.State("api.example.com"); state != CircuitOpen {
+		t.Errorf("Expected 3 of 6 failures in the window to open the circuit, got %s", state)
+	}
+}
+
+func TestCircuitBreakerHalfOpenRequests(t *testing.T) {
+	breaker := newTestCircuitBreaker(WithFailureRate(1, 1), WithHalfOpenRequests(2))
+
+	breaker.send(t, "api.example.com", 1)
+	breaker.clock = breaker.clock.Add(time.Minute)
+	breaker.downstream.statuses["api.example.com"] = http.StatusOK
+
+	breaker.send(t, "api.example.com", 1)
+
+	if state := breaker.State("api.example.com"); state != CircuitHalfOpen {
+		t.Fatalf("Expected the circuit to stay half-open after 1 of 2 trials, got %s", state)
+	}
+
+	breaker.send(t, "api.example.com", 1)
+
+	if state := breaker.State("api.example.com"); state != CircuitClosed {
+		t.Errorf("Expected the circuit to close after 2 trials, got %s", state)
+	}
+}
+
+func TestCircuitBreakerOptions(t *testing.T) {
+	breaker := newTestCircuitBreaker(
+		WithFailureRate(0.5, 4),
+		WithCircuitKey(func(req *http.Request) string { return req.Header.Get("X-Tenant") }),
+		WithCircuitFailure(func(response *http.Response, err error) bool {
+			return err != nil || response.StatusCode == http.StatusTooManyRequests
+		}),
+	)
+
+	breaker.downstream.statuses["api.example.com"] = http.StatusInternalServerError
+
+	for _, tenant := range []string{"a", "a", "b"} {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		breaker.Do(req)
+	}
+
+	if state := breaker.State("a"); state != CircuitClosed {
+		t.Errorf("Expected 500s not to count as failures, got %s", state)
+	}
+
+	breaker.downstream.statuses["api.example.com"] = http.StatusTooManyRequests
+
+	for _, tenant := range []string{"a", "a", "b"} {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		breaker.Do(req)
+	}
+
+	if state := breaker.State("a"); state != CircuitOpen {
+		t.Errorf("Expected tenant a to be open, got %s", state)
+	}
+
+	if state := breaker.State("b"); state != CircuitClosed {
+		t.Errorf("Expected tenant b to be closed, got %s", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelledRequests(t *testing.T) {
+	breaker := newTestCircuitBreaker(WithFailureRate(1, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://api.example.com/", nil)
+	breaker.Do(req)
+
+	if state := breaker.State("api.example.com"); state != CircuitClosed {
+		t.Errorf("Expected a cancelled request not to count, got %s", state)
+	}
+}
+
+func TestCircuitBreakerWithRetryClient(t *testing.T) {
+	breaker := newTestCircuitBreaker(WithFailureRate(1, 1))
+	client, delays := newTestRetryClient(breaker)
+
+	if _, err := client.Do(httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	if breaker.downstream.calls != 1 || len(*delays) != 1 {
+		t.Errorf("Expected 1 call and no retry once the circuit opened, got %d calls and %v", breaker.downstream.calls, *delays)
+	}
+}
@@ -0,0 +1,369 @@
+package filedownloads
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+Compression selects how a ZIP entry is stored. Tar archives ignore it.
+*/
+type Compression int
+
+const (
+	CompressionDefault Compression = iota
+	CompressionDeflate
+	CompressionStore
+)
+
+/*
+ArchiveEntry is a single file in a streamed archive. Provide the content
+either as Content, which may be an io.Reader or an fs.File, or as Open,
+which is called only when the entry is written so that files are not all
+held open at once. Content that implements io.Closer is closed after it
+is written. A Path ending in a slash with no content adds a directory.
+
+Size is only needed for tar archives, and only when it cannot be
+determined from the content. Content without a known size is buffered in
+memory before being written to a tar archive.
+*/
+type ArchiveEntry struct {
+	Path        string
+	Content     io.Reader
+	Open        func() (io.ReadCloser, error)
+	ModTime     time.Time
+	Size        int64
+	Mode        fs.FileMode
+	Compression Compression
+}
+
+/*
+StreamZip writes a ZIP archive directly to the response writer as entries
+are produced, without building it in memory or a temporary file. Entry
+paths are sanitized so they cannot escape the extraction directory
+(zip-slip). ZIP64 records are written automatically for entries larger
+than 4GB or archives with more than 65,535 entries.
+
+Because the archive is streamed, errors after the first entry cannot
+change the status code and leave the client with a truncated archive.
+*/
+func StreamZip(w http.ResponseWriter, filename string, entries iter.Seq[ArchiveEntry], options ...DownloadOption) error {
+	var (
+		err    error
+		header *zip.FileHeader
+		writer io.Writer
+	)
+
+	setDownloadHeaders(w, filename, "application/zip", newDownloadOptions(options))
+
+	zipWriter := zip.NewWriter(w)
+
+	for entry := range entries {
+		if header, err = zipFileHeader(entry); err != nil {
+			return err
+		}
+
+		if writer, err = zipWriter.CreateHeader(header); err != nil {
+			return fmt.Errorf("error creating archive entry '%s': %w", header.Name, err)
+		}
+
+		if header.FileInfo().IsDir() {
+			continue
+		}
+
+		if err = copyArchiveEntry(writer, entry, header.Name); err != nil {
+			return err
+		}
+	}
+
+	if err = zipWriter.Close(); err != nil {
+		return fmt.Errorf("error finishing zip archive: %w", err)
+	}
+
+	return nil
+}
+
+/*
+StreamTar writes an uncompressed tar archive directly to the response
+writer. Entry paths are sanitized the same way as StreamZip.
+*/
+func StreamTar(w http.ResponseWriter, filename string, entries iter.Seq[ArchiveEntry], options ...DownloadOption) error {
+	setDownloadHeaders(w, filename, "application/x-tar", newDownloadOptions(options))
+	return writeTar(w, entries)
+}
+
+/*
+StreamTarGz writes a gzip compressed tar archive directly to the response
+writer. Entry paths are sanitized the same way as StreamZip.
+*/
+func StreamTarGz(w http.ResponseWriter, filename string, entries iter.Seq[ArchiveEntry], options ...DownloadOption) error {
+	var (
+		err error
+	)
+
+	setDownloadHeaders(w, filename, "application/gzip", newDownloadOptions(options))
+
+	gzipWriter := gzip.NewWriter(w)
+
+	if err = writeTar(gzipWriter, entries); err != nil {
+		return err
+	}
+
+	if err = gzipWriter.Close(); err != nil {
+		return fmt.Errorf("error finishing gzip stream: %w", err)
+	}
+
+	return nil
+}
+
+func writeTar(w io.Writer, entries iter.Seq[ArchiveEntry]) error {
+	var (
+		err     error
+		name    string
+		content io.Reader
+		size    int64
+	)
+
+	tarWriter := tar.NewWriter(w)
+
+	for entry := range entries {
+		if name, err = sanitizeArchivePath(entry.Path); err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Name:    name,
+			ModTime: archiveModTime(entry.ModTime),
+			Mode:    int64(entry.Mode.Perm()),
+		}
+
+		if isArchiveDir(entry, name) {
+			header.Typeflag = tar.TypeDir
+
+			if header.Mode == 0 {
+				header.Mode = 0o755
+			}
+
+			if err = tarWriter.WriteHeader(header); err != nil {
+				return fmt.Errorf("error creating archive entry '%s': %w", name, err)
+			}
+
+			continue
+		}
+
+		if content, err = openArchiveEntry(entry); err != nil {
+			return fmt.Errorf("error opening archive entry '%s': %w", name, err)
+		}
+
+		if content, size, err = archiveEntrySize(entry, content); err != nil {
+			closeArchiveEntry(content)
+			return fmt.Errorf("error reading archive entry '%s': %w", name, err)
+		}
+
+		header.Typeflag = tar.TypeReg
+		header.Size = size
+
+		if header.Mode == 0 {
+			header.Mode = 0o644
+		}
+
+		if err = tarWriter.WriteHeader(header); err != nil {
+			closeArchiveEntry(content)
+			return fmt.Errorf("error creating archive entry '%s': %w", name, err)
+		}
+
+		_, err = io.Copy(tarWriter, content)
+		closeArchiveEntry(content)
+
+		if err != nil {
+			return fmt.Errorf("error writing archive entry '%s': %w", name, err)
+		}
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		return fmt.Errorf("error finishing tar archive: %w", err)
+	}
+
+	return nil
+}
+
+func zipFileHeader(entry ArchiveEntry) (*zip.FileHeader, error) {
+	var (
+		err  error
+		name string
+	)
+
+	if name, err = sanitizeArchivePath(entry.Path); err != nil {
+		return nil, err
+	}
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: archiveModTime(entry.ModTime),
+	}
+
+	if entry.Compression == CompressionStore {
+		header.Method = zip.Store
+	}
+
+	if isArchiveDir(entry, name) {
+		if !strings.HasSuffix(header.Name, "/") {
+			header.Name += "/"
+		}
+
+		header.Method = zip.Store
+		header.SetMode(fs.ModeDir | orDefault(entry.Mode.Perm(), 0o755))
+
+		return header, nil
+	}
+
+	header.SetMode(orDefault(entry.Mode.Perm(), 0o644))
+	return header, nil
+}
+
+func copyArchiveEntry(w io.Writer, entry ArchiveEntry, name string) error {
+	var (
+		err     error
+		content io.Reader
+	)
+
+	if content, err = openArchiveEntry(entry); err != nil {
+		return fmt.Errorf("error opening archive entry '%s': %w", name, err)
+	}
+
+	defer closeArchiveEntry(content)
+
+	if _, err = io.Copy(w, content); err != nil {
+		return fmt.Errorf("error writing archive entry '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+func openArchiveEntry(entry ArchiveEntry) (io.Reader, error) {
+	if entry.Open != nil {
+		return entry.Open()
+	}
+
+	if entry.Content == nil {
+		return bytes.NewReader(nil), nil
+	}
+
+	return entry.Content, nil
+}
+
+func closeArchiveEntry(content io.Reader) {
+	if closer, ok := content.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+/*
+archiveEntrySize determines the size of an entry's content for tar
+headers, buffering the content when the size cannot be discovered.
+*/
+func archiveEntrySize(entry ArchiveEntry, content io.Reader) (io.Reader, int64, error) {
+	if entry.Size > 0 {
+		return content, entry.Size, nil
+	}
+
+	switch c := content.(type) {
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if info, err := c.Stat(); err == nil {
+			return content, info.Size(), nil
+		}
+
+	case interface{ Len() int }:
+		return content, int64(c.Len()), nil
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if _, err := io.Copy(buffer, content); err != nil {
+		return content, 0, err
+	}
+
+	closeArchiveEntry(content)
+	return buffer, int64(buffer.Len()), nil
+}
+
+func isArchiveDir(entry ArchiveEntry, name string) bool {
+	if entry.Mode.IsDir() {
+		return true
+	}
+
+	return strings.HasSuffix(name, "/") && entry.Content == nil && entry.Open == nil
+}
+
+func archiveModTime(modTime time.Time) time.Time {
+	if modTime.IsZero() {
+		return time.Now()
+	}
+
+	return modTime
+}
+
+func orDefault(mode, defaultMode fs.FileMode) fs.FileMode {
+	if mode == 0 {
+		return defaultMode
+	}
+
+	return mode
+}
+
+/*
+sanitizeArchivePath turns an entry path into a relative, slash separated
+path that cannot escape the extraction directory. Backslashes are treated
+as separators, drive letters and leading slashes are removed, and empty,
+"." and ".." components are dropped. A trailing slash is preserved.
+*/
+func sanitizeArchivePath(name string) (string, error) {
+	original := name
+	name = strings.ReplaceAll(name, "\\", "/")
+	isDir := strings.HasSuffix(name, "/")
+
+	if len(name) >= 2 && name[1] == ':' && ((name[0] >= 'a' && name[0] <= 'z') || (name[0] >= 'A' && name[0] <= 'Z')) {
+		name = name[2:]
+	}
+
+	parts := []string{}
+
+	for _, part := range strings.Split(name, "/") {
+		part = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+
+			return r
+		}, part)
+
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("invalid archive entry path '%s'", original)
+	}
+
+	result := strings.Join(parts, "/")
+
+	if isDir {
+		result += "/"
+	}
+
+	return result, nil
+}
@@ -0,0 +1,282 @@
+package filedownloads
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func archiveTestEntries(t *testing.T) iter.Seq[ArchiveEntry] {
+	t.Helper()
+
+	modTime := time.Date(2026, 5, 1, 10, 30, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"docs/manual.txt": &fstest.MapFile{Data: []byte("from fs.File"), ModTime: modTime},
+	}
+
+	return slices.Values([]ArchiveEntry{
+		{Path: "readme.txt", Content: strings.NewReader("hello"), ModTime: modTime},
+		{Path: "../../etc/passwd", Content: bytes.NewReader([]byte("slip")), ModTime: modTime},
+		{Path: `C:\Users\adam\report.csv`, Content: strings.NewReader("a,b"), ModTime: modTime, Compression: CompressionStore},
+		{Path: "images/", ModTime: modTime},
+		{Path: "docs/manual.txt", ModTime: modTime, Open: func() (io.ReadCloser, error) {
+			return fsys.Open("docs/manual.txt")
+		}},
+		{Path: "/abs/./unknown-size.bin", Content: io.MultiReader(strings.NewReader("unknown "), strings.NewReader("size")), ModTime: modTime},
+	})
+}
+
+var archiveTestExpected = map[string]string{
+	"readme.txt":            "hello",
+	"etc/passwd":            "slip",
+	"Users/adam/report.csv": "a,b",
+	"images/":               "",
+	"docs/manual.txt":       "from fs.File",
+	"abs/unknown-size.bin":  "unknown size",
+}
+
+func TestStreamZip(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	if err := StreamZip(w, "documents.zip", archiveTestEntries(t)); err != nil {
+		t.Fatalf("StreamZip failed: %v", err)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("Expected Content-Type 'application/zip', got '%s'", contentType)
+	}
+
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename="documents.zip"` {
+		t.Errorf("Unexpected Content-Disposition '%s'", disposition)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+
+	got := map[string]string{}
+
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		got[file.Name] = string(b)
+
+		switch file.Name {
+		case "Users/adam/report.csv", "images/":
+			if file.Method != zip.Store {
+				t.Errorf("Expected %s to be stored, got method %d", file.Name, file.Method)
+			}
+
+		default:
+			if file.Method != zip.Deflate {
+				t.Errorf("Expected %s to be deflated, got method %d", file.Name, file.Method)
+			}
+		}
+
+		if file.Name == "images/" && !file.FileInfo().IsDir() {
+			t.Error("Expected images/ to be a directory")
+		}
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(archiveTestExpected) {
+		t.Errorf("Expected entries %v, got %v", archiveTestExpected, got)
+	}
+}
+
+func TestStreamZipManyEntries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping ZIP64 entry count test in short mode")
+	}
+
+	const count = 65_600
+
+	entries := func(yield func(ArchiveEntry) bool) {
+		for index := range count {
+			if !yield(ArchiveEntry{Path: fmt.Sprintf("%d.txt", index), Compression: CompressionStore, Content: strings.NewReader("")}) {
+				return
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+
+	if err := StreamZip(w, "many.zip", entries); err != nil {
+		t.Fatalf("StreamZip failed: %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+
+	if len(reader.File) != count {
+		t.Errorf("Expected %d entries, got %d", count, len(reader.File))
+	}
+}
+
+func TestStreamZipErrors(t *testing.T) {
+	t.Run("InvalidPath", func(t *testing.T) {
+		entries := slices.Values([]ArchiveEntry{{Path: "../..", Content: strings.NewReader("x")}})
+
+		if err := StreamZip(httptest.NewRecorder(), "bad.zip", entries); err == nil || !strings.Contains(err.Error(), "invalid archive entry path") {
+			t.Errorf("Expected an invalid path error, got %v", err)
+		}
+	})
+
+	t.Run("OpenFails", func(t *testing.T) {
+		entries := slices.Values([]ArchiveEntry{{Path: "missing.txt", Open: func() (io.ReadCloser, error) {
+			return nil, os.ErrNotExist
+		}}})
+
+		if err := StreamZip(httptest.NewRecorder(), "bad.zip", entries); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected os.ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func readTestTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+
+	got := map[string]string{}
+	reader := tar.NewReader(r)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+
+		b, _ := io.ReadAll(reader)
+		got[header.Name] = string(b)
+
+		if header.Name == "images/" && header.Typeflag != tar.TypeDir {
+			t.Error("Expected images/ to be a directory")
+		}
+	}
+
+	return got
+}
+
+func TestStreamTar(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	if err := StreamTar(w, "documents.tar", archiveTestEntries(t)); err != nil {
+		t.Fatalf("StreamTar failed: %v", err)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-tar" {
+		t.Errorf("Expected Content-Type 'application/x-tar', got '%s'", contentType)
+	}
+
+	got := readTestTar(t, w.Body)
+
+	if fmt.Sprint(got) != fmt.Sprint(archiveTestExpected) {
+		t.Errorf("Expected entries %v, got %v", archiveTestExpected, got)
+	}
+}
+
+func TestStreamTarGz(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	if err := StreamTarGz(w, "documents.tar.gz", archiveTestEntries(t)); err != nil {
+		t.Fatalf("StreamTarGz failed: %v", err)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/gzip" {
+		t.Errorf("Expected Content-Type 'application/gzip', got '%s'", contentType)
+	}
+
+	gzipReader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip: %v", err)
+	}
+
+	got := readTestTar(t, gzipReader)
+
+	if fmt.Sprint(got) != fmt.Sprint(archiveTestExpected) {
+		t.Errorf("Expected entries %v, got %v", archiveTestExpected, got)
+	}
+}
+
+func TestStreamTarFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+
+	if err := os.WriteFile(path, []byte("on disk"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+
+	if err = StreamTar(w, "disk.tar", slices.Values([]ArchiveEntry{{Path: "data.bin", Content: file}})); err != nil {
+		t.Fatalf("StreamTar failed: %v", err)
+	}
+
+	if got := readTestTar(t, w.Body); got["data.bin"] != "on disk" {
+		t.Errorf("Expected 'on disk', got %v", got)
+	}
+
+	if _, err = file.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected the file to be closed after writing, got %v", err)
+	}
+}
+
+func TestSanitizeArchivePath(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		isError  bool
+	}{
+		{"a/b.txt", "a/b.txt", false},
+		{"../../evil.sh", "evil.sh", false},
+		{"/etc/passwd", "etc/passwd", false},
+		{`..\..\windows\system32`, "windows/system32", false},
+		{"D:/data/./file.txt", "data/file.txt", false},
+		{"dir/sub/", "dir/sub/", false},
+		{"bad\x00name\n.txt", "badname.txt", false},
+		{"..", "", true},
+		{"", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := sanitizeArchivePath(tc.input)
+
+			if (err != nil) != tc.isError {
+				t.Fatalf("Expected error %v, got %v", tc.isError, err)
+			}
+
+			if got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package filedownloads
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DispositionAttachment = "attachment"
+	DispositionInline     = "inline"
+)
+
+/*
+asciiFallbacks transliterates common accented Latin letters for the plain
+filename parameter. Anything else outside of ASCII becomes an underscore.
+*/
+var asciiFallbacks = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'ÿ': "y",
+	'Œ': "OE", 'œ': "oe", 'Š': "S", 'š': "s", 'Ž': "Z", 'ž': "z", 'Ł': "L", 'ł': "l",
+}
+
+/*
+ContentDisposition builds a Content-Disposition header value following
+RFC 6266. Control characters, including CR and LF, are removed so the
+filename cannot inject headers. Names that are not plain ASCII get an
+ASCII fallback in the filename parameter, plus the exact name encoded as
+UTF-8 in the filename* parameter (RFC 5987).
+*/
+func ContentDisposition(dispositionType, filename string) string {
+	if dispositionType == "" {
+		dispositionType = DispositionAttachment
+	}
+
+	filename = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, strings.ToValidUTF8(filename, ""))
+
+	if filename == "" {
+		return dispositionType
+	}
+
+	fallback := strings.Builder{}
+	isASCII := true
+
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\':
+			fallback.WriteRune('\\')
+			fallback.WriteRune(r)
+
+		case r < utf8.RuneSelf:
+			fallback.WriteRune(r)
+
+		default:
+			isASCII = false
+
+			if replacement, ok := asciiFallbacks[r]; ok {
+				fallback.WriteString(replacement)
+			} else {
+				fallback.WriteRune('_')
+			}
+		}
+	}
+
+	result := fmt.Sprintf("%s; filename=\"%s\"", dispositionType, fallback.String())
+
+	if !isASCII {
+		result += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+
+	return result
+}
+
+/*
+WithDisposition sets the disposition type, such as DispositionInline to
+let the browser display the content instead of saving it.
+*/
+func WithDisposition(dispositionType string) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.Disposition = dispositionType
+	}
+}
+
+/*
+WithInline asks the browser to display the content instead of saving it.
+*/
+func WithInline() DownloadOption {
+	return WithDisposition(DispositionInline)
+}
+
+/*
+encodeExtValue percent-encodes every byte that is not an attr-char from
+RFC 5987.
+*/
+func encodeExtValue(value string) string {
+	const hex = "0123456789ABCDEF"
+
+	result := strings.Builder{}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		if isAttrChar(c) {
+			result.WriteByte(c)
+			continue
+		}
+
+		result.WriteByte('%')
+		result.WriteByte(hex[c>>4])
+		result.WriteByte(hex[c&0x0f])
+	}
+
+	return result.String()
+}
+
+func isAttrChar(c byte) bool {
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
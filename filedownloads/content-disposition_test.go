@@ -0,0 +1,84 @@
+package filedownloads
+
+import (
+	"mime"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	testCases := []struct {
+		name            string
+		dispositionType string
+		filename        string
+		expected        string
+	}{
+		{"Plain", DispositionAttachment, "report.csv", `attachment; filename="report.csv"`},
+		{"DefaultType", "", "report.csv", `attachment; filename="report.csv"`},
+		{"Inline", DispositionInline, "photo.png", `inline; filename="photo.png"`},
+		{"Quotes", DispositionAttachment, `my "best" \ file.txt`, `attachment; filename="my \"best\" \\ file.txt"`},
+		{"HeaderInjection", DispositionAttachment, "evil.txt\r\nSet-Cookie: a=b", `attachment; filename="evil.txtSet-Cookie: a=b"`},
+		{"Accented", DispositionAttachment, "résumé.pdf", `attachment; filename="resume.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"Japanese", DispositionAttachment, "報告書.xlsx", `attachment; filename="___.xlsx"; filename*=UTF-8''%E5%A0%B1%E5%91%8A%E6%9B%B8.xlsx`},
+		{"SpacesEncodedInExtValue", DispositionAttachment, "naïve plan.txt", `attachment; filename="naive plan.txt"; filename*=UTF-8''na%C3%AFve%20plan.txt`},
+		{"InvalidUTF8", DispositionAttachment, "bad\xffname.txt", `attachment; filename="badname.txt"`},
+		{"Empty", DispositionAttachment, "\r\n", `attachment`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ContentDisposition(tc.dispositionType, tc.filename)
+
+			if got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+
+			if _, _, err := mime.ParseMediaType(got); err != nil {
+				t.Errorf("Expected a parseable header, got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestContentDispositionRoundTrip(t *testing.T) {
+	filename := "Café «menu» 2026.pdf"
+
+	_, params, err := mime.ParseMediaType(ContentDisposition(DispositionAttachment, filename))
+	if err != nil {
+		t.Fatalf("Failed to parse header: %v", err)
+	}
+
+	// mime.ParseMediaType prefers the decoded filename* value
+	if params["filename"] != filename {
+		t.Errorf("Expected '%s', got '%s'", filename, params["filename"])
+	}
+}
+
+func TestDownloadOptionsDisposition(t *testing.T) {
+	t.Run("StreamBytes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		if err := StreamBytes(w, "photo.png", "image/png", []byte{1}, WithInline()); err != nil {
+			t.Fatalf("StreamBytes failed: %v", err)
+		}
+
+		if disposition := w.Header().Get("Content-Disposition"); disposition != `inline; filename="photo.png"` {
+			t.Errorf("Expected an inline disposition, got '%s'", disposition)
+		}
+	})
+
+	t.Run("ServeContent", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+
+		ServeContent(w, req, "données.csv", "text/csv", strings.NewReader("a,b"), time.Time{}, WithDisposition(DispositionInline))
+
+		expected := `inline; filename="donnees.csv"; filename*=UTF-8''donn%C3%A9es.csv`
+
+		if disposition := w.Header().Get("Content-Disposition"); disposition != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, disposition)
+		}
+	})
+}
@@ -7,24 +7,31 @@ import (
 	"os"
 )
 
+type DownloadOptions struct {
+	Disposition string
+	ETag        string
+}
+
+type DownloadOption func(o *DownloadOptions)
+
 /*
 DownloadCsv downloads a byte slice to the provided HTTP response writer as a CSV file.
 */
-func DownloadCsv(w http.ResponseWriter, filename string, content []byte) error {
-	return StreamBytes(w, filename, "text/csv", content)
+func DownloadCsv(w http.ResponseWriter, filename string, content []byte, options ...DownloadOption) error {
+	return StreamBytes(w, filename, "text/csv", content, options...)
 }
 
 /*
 DownloadCsvFile downloads an os.File to the provided HTTP response writer as a CSV file.
 */
-func DownloadCsvFile(w http.ResponseWriter, filename string, file *os.File) error {
-	return DownloadFile(w, filename, "text/csv", file)
+func DownloadCsvFile(w http.ResponseWriter, filename string, file *os.File, options ...DownloadOption) error {
+	return DownloadFile(w, filename, "text/csv", file, options...)
 }
 
 /*
 DownloadFile downloads an os.File to the provided HTTP response writer.
 */
-func DownloadFile(w http.ResponseWriter, filename, contentType string, file *os.File) error {
+func DownloadFile(w http.ResponseWriter, filename, contentType string, file *os.File, options ...DownloadOption) error {
 	var (
 		err      error
 		fileInfo os.FileInfo
@@ -34,15 +41,14 @@ func DownloadFile(w http.ResponseWriter, filename, contentType string, file *os.
 		return fmt.Errorf("error getting file info: %w", err)
 	}
 
-	return StreamContent(w, filename, contentType, file, fileInfo.Size())
+	return StreamContent(w, filename, contentType, file, fileInfo.Size(), options...)
 }
 
 /*
 StreamBytes writes a byte slice to the provided HTTP response writer.
 */
-func StreamBytes(w http.ResponseWriter, filename, contentType string, content []byte) error {
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", contentType)
+func StreamBytes(w http.ResponseWriter, filename, contentType string, content []byte, options ...DownloadOption) error {
+	setDownloadHeaders(w, filename, contentType, newDownloadOptions(options))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
 
 	_, err := w.Write(content)
@@ -52,11 +58,27 @@ func StreamBytes(w http.ResponseWriter, filename, contentType string, content []
 /*
 StreamContent writes an io.Reader to the provided HTTP response writer.
 */
-func StreamContent(w http.ResponseWriter, filename, contentType string, content io.Reader, size int64) error {
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", contentType)
+func StreamContent(w http.ResponseWriter, filename, contentType string, content io.Reader, size int64, options ...DownloadOption) error {
+	setDownloadHeaders(w, filename, contentType, newDownloadOptions(options))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
 
 	_, err := io.Copy(w, content)
 	return err
 }
+
+func newDownloadOptions(options []DownloadOption) *DownloadOptions {
+	result := &DownloadOptions{
+		Disposition: DispositionAttachment,
+	}
+
+	for _, opt := range options {
+		opt(result)
+	}
+
+	return result
+}
+
+func setDownloadHeaders(w http.ResponseWriter, filename, contentType string, opts *DownloadOptions) {
+	w.Header().Set("Content-Disposition", ContentDisposition(opts.Disposition, filename))
+	w.Header().Set("Content-Type", contentType)
+}
@@ -0,0 +1,229 @@
+package filedownloads
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeContent(t *testing.T) {
+	content := "0123456789abcdefghij"
+	modTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	serve := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/export.csv", nil)
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		ServeContent(w, req, "export.csv", "text/csv", strings.NewReader(content), modTime, WithETag("v1"))
+		return w
+	}
+
+	t.Run("FullBody", func(t *testing.T) {
+		w := serve("GET", nil)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if w.Body.String() != content {
+			t.Errorf("Expected full body, got '%s'", w.Body.String())
+		}
+
+		if etag := w.Header().Get("ETag"); etag != `"v1"` {
+			t.Errorf("Expected ETag '\"v1\"', got '%s'", etag)
+		}
+
+		if lastModified := w.Header().Get("Last-Modified"); lastModified != modTime.Format(http.TimeFormat) {
+			t.Errorf("Expected Last-Modified '%s', got '%s'", modTime.Format(http.TimeFormat), lastModified)
+		}
+
+		if acceptRanges := w.Header().Get("Accept-Ranges"); acceptRanges != "bytes" {
+			t.Errorf("Expected Accept-Ranges 'bytes', got '%s'", acceptRanges)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/csv" {
+			t.Errorf("Expected Content-Type 'text/csv', got '%s'", contentType)
+		}
+	})
+
+	t.Run("SingleRange", func(t *testing.T) {
+		w := serve("GET", map[string]string{"Range": "bytes=5-9"})
+
+		if w.Code != http.StatusPartialContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusPartialContent, w.Code)
+		}
+
+		if w.Body.String() != "56789" {
+			t.Errorf("Expected '56789', got '%s'", w.Body.String())
+		}
+
+		if contentRange := w.Header().Get("Content-Range"); contentRange != "bytes 5-9/20" {
+			t.Errorf("Expected Content-Range 'bytes 5-9/20', got '%s'", contentRange)
+		}
+	})
+
+	t.Run("MultipleRanges", func(t *testing.T) {
+		w := serve("GET", map[string]string{"Range": "bytes=0-1,-2"})
+
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("Expected status code %d, got %d", http.StatusPartialContent, w.Code)
+		}
+
+		mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		if err != nil || mediaType != "multipart/byteranges" {
+			t.Fatalf("Expected multipart/byteranges, got '%s'", w.Header().Get("Content-Type"))
+		}
+
+		reader := multipart.NewReader(w.Body, params["boundary"])
+		parts := []string{}
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to read part: %v", err)
+			}
+
+			b, _ := io.ReadAll(part)
+			parts = append(parts, part.Header.Get("Content-Range")+"="+string(b))
+		}
+
+		expected := "bytes 0-1/20=01,bytes 18-19/20=ij"
+
+		if strings.Join(parts, ",") != expected {
+			t.Errorf("Expected parts '%s', got '%s'", expected, strings.Join(parts, ","))
+		}
+	})
+
+	t.Run("UnsatisfiableRange", func(t *testing.T) {
+		w := serve("GET", map[string]string{"Range": "bytes=50-60"})
+
+		if w.Code != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("Expected status code %d, got %d", http.StatusRequestedRangeNotSatisfiable, w.Code)
+		}
+	})
+
+	t.Run("IfNoneMatch", func(t *testing.T) {
+		w := serve("GET", map[string]string{"If-None-Match": `"v0", "v1"`})
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotModified, w.Code)
+		}
+
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected an empty body, got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("IfModifiedSince", func(t *testing.T) {
+		w := serve("GET", map[string]string{"If-Modified-Since": modTime.Add(time.Hour).Format(http.TimeFormat)})
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotModified, w.Code)
+		}
+
+		w = serve("GET", map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)})
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d for a stale date, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("IfRange", func(t *testing.T) {
+		w := serve("GET", map[string]string{"Range": "bytes=0-3", "If-Range": `"v1"`})
+
+		if w.Code != http.StatusPartialContent || w.Body.String() != "0123" {
+			t.Errorf("Expected a partial response for a matching If-Range, got %d '%s'", w.Code, w.Body.String())
+		}
+
+		w = serve("GET", map[string]string{"Range": "bytes=0-3", "If-Range": `"v0"`})
+
+		if w.Code != http.StatusOK || w.Body.String() != content {
+			t.Errorf("Expected the full body for a stale If-Range, got %d '%s'", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Head", func(t *testing.T) {
+		w := serve("HEAD", nil)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected no body for HEAD, got '%s'", w.Body.String())
+		}
+
+		if length := w.Header().Get("Content-Length"); length != "20" {
+			t.Errorf("Expected Content-Length '20', got '%s'", length)
+		}
+	})
+}
+
+func TestServeReaderAt(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/video.mp4", nil)
+	req.Header.Set("Range", "bytes=2-")
+
+	ServeReaderAt(w, req, "video.mp4", "video/mp4", strings.NewReader("abcdef"), 6, time.Time{})
+
+	if w.Code != http.StatusPartialContent || w.Body.String() != "cdef" {
+		t.Errorf("Expected 206 'cdef', got %d '%s'", w.Code, w.Body.String())
+	}
+}
+
+func TestServeFile(t *testing.T) {
+	tmpFile, err := os.CreateTemp(t.TempDir(), "test-*.txt")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	defer tmpFile.Close()
+
+	if _, err = tmpFile.WriteString("file content"); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/download.txt", nil)
+
+	if err = ServeFile(w, req, "download.txt", "text/plain", tmpFile); err != nil {
+		t.Fatalf("ServeFile failed: %v", err)
+	}
+
+	etag := w.Header().Get("ETag")
+
+	if w.Body.String() != "file content" || etag == "" {
+		t.Fatalf("Expected the file with an ETag, got '%s' and ETag '%s'", w.Body.String(), etag)
+	}
+
+	w = httptest.NewRecorder()
+	req.Header.Set("If-None-Match", etag)
+
+	if err = ServeFile(w, req, "download.txt", "text/plain", tmpFile); err != nil {
+		t.Fatalf("ServeFile failed: %v", err)
+	}
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code %d for a matching ETag, got %d", http.StatusNotModified, w.Code)
+	}
+
+	tmpFile.Close()
+
+	if err = ServeFile(httptest.NewRecorder(), req, "download.txt", "text/plain", tmpFile); err == nil {
+		t.Error("Expected an error for a closed file, but got nil")
+	}
+}
@@ -0,0 +1,252 @@
+package fileuploads
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrDigestMismatch = errors.New("file does not match the supplied digest")
+)
+
+/*
+ChecksumAlgorithm names an optional checksum computed for uploaded files.
+SHA-256 is always computed.
+*/
+type ChecksumAlgorithm string
+
+const (
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+	ChecksumSHA1   ChecksumAlgorithm = "sha1"
+	ChecksumCRC32C ChecksumAlgorithm = "crc32c"
+)
+
+/*
+Checksums holds the hex encoded checksums of an uploaded file. Optional
+checksums are empty unless requested with WithChecksums.
+*/
+type Checksums struct {
+	SHA256 string
+	MD5    string
+	SHA1   string
+	CRC32C string
+}
+
+/*
+WithChecksums computes the given checksums in addition to SHA-256.
+*/
+func WithChecksums(algorithms ...ChecksumAlgorithm) UploadOption {
+	return func(o *UploadOptions) {
+		o.Checksums = append(o.Checksums, algorithms...)
+	}
+}
+
+/*
+WithDigestVerification verifies each file against a Content-Digest or
+Repr-Digest header (RFC 9530) sent with its multipart part, such as
+"Content-Digest: sha-256=:base64digest=:". Files whose content does not
+match fail with ErrDigestMismatch. Files sent without a digest header are
+accepted. The sha-256 and sha-512 algorithms are supported.
+*/
+func WithDigestVerification() UploadOption {
+	return func(o *UploadOptions) {
+		o.VerifyDigest = true
+	}
+}
+
+/*
+checksummer hashes a file as it is written to it, and verifies the result
+against the digest supplied by the client. It is fed while a file is
+saved, assembled or streamed, so computing checksums costs no extra pass.
+*/
+type checksummer struct {
+	fileName string
+	hashes   map[string]hash.Hash
+	digest   map[string][]byte
+}
+
+func newChecksummer(fileName string, header textproto.MIMEHeader, opts *UploadOptions) (*checksummer, error) {
+	var (
+		err    error
+		digest map[string][]byte
+	)
+
+	if opts.VerifyDigest {
+		if digest, err = parseDigestHeader(header); err != nil {
+			return nil, fmt.Errorf("error reading digest for file '%s': %w", fileName, err)
+		}
+	}
+
+	hashes := map[string]hash.Hash{"sha256": sha256.New()}
+
+	for _, algorithm := range opts.Checksums {
+		switch algorithm {
+		case ChecksumMD5:
+			hashes["md5"] = md5.New()
+
+		case ChecksumSHA1:
+			hashes["sha1"] = sha1.New()
+
+		case ChecksumCRC32C:
+			hashes["crc32c"] = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+
+		default:
+			return nil, fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
+		}
+	}
+
+	if _, ok := digest["sha-512"]; ok {
+		hashes["sha512"] = sha512.New()
+	}
+
+	return &checksummer{fileName: fileName, hashes: hashes, digest: digest}, nil
+}
+
+func (c *checksummer) Write(p []byte) (int, error) {
+	for _, h := range c.hashes {
+		h.Write(p)
+	}
+
+	return len(p), nil
+}
+
+/*
+checksums returns the checksums of everything written so far.
+*/
+func (c *checksummer) checksums() Checksums {
+	return Checksums{
+		SHA256: c.sum("sha256"),
+		MD5:    c.sum("md5"),
+		SHA1:   c.sum("sha1"),
+		CRC32C: c.sum("crc32c"),
+	}
+}
+
+func (c *checksummer) sum(name string) string {
+	if h, ok := c.hashes[name]; ok {
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	return ""
+}
+
+/*
+verify checks everything written so far against the client's digest,
+returning ErrDigestMismatch when they differ.
+*/
+func (c *checksummer) verify() error {
+	for algorithm, expected := range c.digest {
+		h := c.hashes[strings.ReplaceAll(algorithm, "-", "")]
+
+		if !bytes.Equal(h.Sum(nil), expected) {
+			return fmt.Errorf("file '%s' does not match its %s digest: %w", c.fileName, algorithm, ErrDigestMismatch)
+		}
+	}
+
+	return nil
+}
+
+/*
+computeChecksums hashes an uploaded file in a pass of its own and, when
+requested, verifies it against the digest supplied by the client. It is
+only used when the checksums are needed before the file is read for any
+other reason, such as for a ReadUploadedFile callback or a file name
+template. The file is read through ReadAt, so its read position is left
+untouched.
+*/
+func computeChecksums(file *FileUpload, opts *UploadOptions) error {
+	sums, err := newChecksummer(file.FileName, file.Header, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(sums, io.NewSectionReader(file.File, 0, file.Size)); err != nil {
+		return fmt.Errorf("error computing checksums for file '%s': %w", file.FileName, err)
+	}
+
+	file.Checksums = sums.checksums()
+	return sums.verify()
+}
+
+/*
+checksumReader feeds everything read through it to a checksummer, and
+verifies the digest once the end is reached, so a reader sees
+ErrDigestMismatch in place of io.EOF.
+*/
+type checksumReader struct {
+	reader io.Reader
+	sums   *checksummer
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.sums.Write(p[:n])
+
+	if err == io.EOF {
+		if verifyErr := r.sums.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+
+	return n, err
+}
+
+/*
+parseDigestHeader reads the supported algorithms from a Content-Digest or
+Repr-Digest header, which is a structured field dictionary of byte
+sequences. A header that names only unsupported algorithms is an error,
+since the file could not be verified.
+*/
+func parseDigestHeader(header textproto.MIMEHeader) (map[string][]byte, error) {
+	value := header.Get("Content-Digest")
+
+	if value == "" {
+		value = header.Get("Repr-Digest")
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	result := map[string][]byte{}
+
+	for member := range strings.SplitSeq(value, ",") {
+		algorithm, encoded, ok := strings.Cut(strings.TrimSpace(member), "=")
+		encoded, _, _ = strings.Cut(encoded, ";")
+		algorithm = strings.ToLower(algorithm)
+
+		if !ok || len(encoded) < 2 || encoded[0] != ':' || encoded[len(encoded)-1] != ':' {
+			return nil, fmt.Errorf("malformed digest '%s'", member)
+		}
+
+		if !slices.Contains([]string{"sha-256", "sha-512"}, algorithm) {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded[1 : len(encoded)-1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s digest: %w", algorithm, err)
+		}
+
+		result[algorithm] = decoded
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no supported algorithm in digest '%s'", value)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,219 @@
+package fileuploads
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	helloWorldSHA256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+)
+
+/*
+createDigestRequest is a helper function to create a new HTTP request
+containing a file part with the given digest header.
+*/
+func createDigestRequest(t *testing.T, headerName, digest, content string) *http.Request {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="upload"; filename="hello.txt"`)
+	header.Set("Content-Type", "text/plain")
+
+	if digest != "" {
+		header.Set(headerName, digest)
+	}
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create part: %v", err)
+	}
+
+	io.WriteString(part, content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+func TestReadUploadedFileChecksums(t *testing.T) {
+	req := createMultipartRequest(t, "upload", "hello.txt", "hello world")
+	got := Checksums{}
+
+	err := ReadUploadedFile("upload", req, func(file FileUpload, options *UploadOptions) error {
+		got = file.Checksums
+
+		// Checksums must not move the read position
+		content, _ := io.ReadAll(file.File)
+
+		if string(content) != "hello world" {
+			t.Errorf("Expected the full content, got '%s'", content)
+		}
+
+		return nil
+	}, WithChecksums(ChecksumMD5, ChecksumSHA1, ChecksumCRC32C))
+
+	if err != nil {
+		t.Fatalf("ReadUploadedFile failed: %v", err)
+	}
+
+	expected := Checksums{
+		SHA256: helloWorldSHA256,
+		MD5:    "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		SHA1:   "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+		CRC32C: "c99465aa",
+	}
+
+	if got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestUploadFileToDirChecksumTemplate(t *testing.T) {
+	destDir := t.TempDir()
+	req := createMultipartRequest(t, "upload", "hello.txt", "hello world")
+
+	upload, err := UploadFileToDir("upload", req, destDir, "{{.sha256}}{{.ext}}")
+	if err != nil {
+		t.Fatalf("UploadFileToDir failed: %v", err)
+	}
+
+	if filepath.Base(upload.SavedFile) != helloWorldSHA256+".txt" {
+		t.Errorf("Expected the file to be named by its hash, got '%s'", upload.SavedFile)
+	}
+
+	req = createMultipartRequest(t, "upload", "hello.txt", "hello world")
+
+	if _, err = UploadFileToDir("upload", req, destDir, "{{.md5}}{{.ext}}"); err == nil {
+		t.Error("Expected an error for a checksum that was not requested")
+	}
+}
+
+func TestDigestVerification(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello world"))
+	valid := fmt.Sprintf("sha-256=:%s:", base64.StdEncoding.EncodeToString(sum[:]))
+
+	testCases := []struct {
+		name       string
+		headerName string
+		digest     string
+		expected   error
+		isError    bool
+	}{
+		{"ContentDigest", "Content-Digest", valid, nil, false},
+		{"ReprDigest", "Repr-Digest", valid, nil, false},
+		{"WithSha512", "Content-Digest", valid + ", sha-512=:MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw==:", nil, false},
+		{"UnknownAlgorithmIgnored", "Content-Digest", "md5=:XrY7u+Ae7tCTyyK7j1rNww==:, " + valid, nil, false},
+		{"NoHeader", "Content-Digest", "", nil, false},
+		{"Mismatch", "Content-Digest", "sha-256=:" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + ":", ErrDigestMismatch, true},
+		{"Unsupported", "Content-Digest", "md5=:XrY7u+Ae7tCTyyK7j1rNww==:", nil, true},
+		{"Malformed", "Content-Digest", "sha-256=abc", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createDigestRequest(t, tc.headerName, tc.digest, "hello world")
+
+			err := ReadUploadedFile("upload", req, func(file FileUpload, options *UploadOptions) error {
+				return nil
+			}, WithDigestVerification())
+
+			if (err != nil) != tc.isError {
+				t.Fatalf("Expected error %v, got %v", tc.isError, err)
+			}
+
+			if tc.expected != nil && !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestUploadFileChecksumsWhileSaving(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello world"))
+	mismatch := "sha-256=:" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + ":"
+
+	testCases := []struct {
+		name     string
+		digest   string
+		expected error
+	}{
+		{"Valid", fmt.Sprintf("sha-256=:%s:", base64.StdEncoding.EncodeToString(sum[:])), nil},
+		{"Mismatch", mismatch, ErrDigestMismatch},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := NewMemoryStorage()
+			req := createDigestRequest(t, "Content-Digest", tc.digest, "hello world")
+
+			upload, err := UploadFile(storage, "upload", req, "{{.baseFileName}}", WithDigestVerification(), WithChecksums(ChecksumMD5))
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if upload.Checksums.SHA256 != helloWorldSHA256 || upload.Checksums.MD5 != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+				t.Errorf("Expected the checksums of the saved file, got %+v", upload.Checksums)
+			}
+
+			objects, _ := storage.List(context.Background(), "")
+
+			if (len(objects) == 1) != (tc.expected == nil) {
+				t.Errorf("Expected a file that fails verification to be deleted, got %+v", objects)
+			}
+		})
+	}
+}
+
+func TestStreamUploadedFilesChecksums(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello world"))
+	mismatch := "sha-256=:" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + ":"
+
+	testCases := []struct {
+		name     string
+		digest   string
+		expected error
+	}{
+		{"Valid", fmt.Sprintf("sha-256=:%s:", base64.StdEncoding.EncodeToString(sum[:])), nil},
+		{"Mismatch", mismatch, ErrDigestMismatch},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createDigestRequest(t, "Content-Digest", tc.digest, "hello world")
+			got := Checksums{}
+
+			_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+				_, err := io.Copy(io.Discard, part.Reader)
+				got = part.Checksums()
+
+				return err
+			}, WithDigestVerification())
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if got.SHA256 != helloWorldSHA256 {
+				t.Errorf("Expected SHA256 '%s', got '%s'", helloWorldSHA256, got.SHA256)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package fileuploads
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const clamdChunkSize = 64 << 10
+
+/*
+ClamdScanner scans files with a ClamAV daemon using its INSTREAM command.
+Network is "tcp" or "unix", and Address is a host and port, such as
+"localhost:3310", or the path to clamd's socket. Each scan gives up after
+Timeout, unless the context passed to Scan ends sooner.
+
+Content larger than clamd's StreamMaxLength setting is refused by the
+daemon, so make sure it is at least as large as WithMaxFileSize.
+*/
+type ClamdScanner struct {
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+func NewClamdScanner(network, address string) *ClamdScanner {
+	return &ClamdScanner{
+		Network: network,
+		Address: address,
+		Timeout: 30 * time.Second,
+	}
+}
+
+/*
+Scan streams content to clamd. It returns an *InfectedError naming the
+signature clamd found, or nil when the content is clean.
+*/
+func (s *ClamdScanner) Scan(ctx context.Context, content io.Reader) error {
+	var (
+		err      error
+		conn     net.Conn
+		reply    string
+		readErr  error
+		writeErr error
+	)
+
+	if conn, err = s.dial(ctx); err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if readErr, writeErr = writeClamdStream(conn, content); readErr != nil {
+		return fmt.Errorf("error reading content to scan: %w", readErr)
+	}
+
+	// clamd replies early, for example when the stream is too large, so
+	// read its reply even if the content could not be sent in full.
+	if reply, err = readClamdReply(conn); err != nil {
+		if writeErr != nil {
+			return fmt.Errorf("error sending content to clamd: %w", writeErr)
+		}
+
+		return err
+	}
+
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	if signature, ok := strings.CutSuffix(reply, " FOUND"); ok {
+		return &InfectedError{Signature: signature}
+	}
+
+	if reply != "OK" {
+		return fmt.Errorf("clamd returned '%s'", reply)
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("error sending content to clamd: %w", writeErr)
+	}
+
+	return nil
+}
+
+/*
+Ping checks that clamd is reachable and responding.
+*/
+func (s *ClamdScanner) Ping(ctx context.Context) error {
+	var (
+		err   error
+		conn  net.Conn
+		reply string
+	)
+
+	if conn, err = s.dial(ctx); err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte("zPING\x00")); err != nil {
+		return fmt.Errorf("error sending command to clamd: %w", err)
+	}
+
+	if reply, err = readClamdReply(conn); err != nil {
+		return err
+	}
+
+	if reply != "PONG" {
+		return fmt.Errorf("clamd returned '%s'", reply)
+	}
+
+	return nil
+}
+
+/*
+dial connects to clamd. The connection's deadline is the earlier of the
+scanner's timeout and the context's deadline, and any read or write in
+progress is interrupted if the context is cancelled.
+*/
+func (s *ClamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{}
+
+	if s.Timeout > 0 {
+		dialer.Deadline = time.Now().Add(s.Timeout)
+	}
+
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to clamd at '%s': %w", s.Address, err)
+	}
+
+	deadline, ok := ctx.Deadline()
+
+	if !dialer.Deadline.IsZero() && (!ok || dialer.Deadline.Before(deadline)) {
+		deadline, ok = dialer.Deadline, true
+	}
+
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	return &clamdConn{Conn: conn, stop: stop}, nil
+}
+
+/*
+clamdConn stops watching the context once the connection is closed.
+*/
+type clamdConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *clamdConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+/*
+writeClamdStream sends the INSTREAM command followed by the content in
+length prefixed chunks, and a zero length chunk to mark the end. Errors
+reading the content are returned as readErr, since clamd will not reply
+to a stream that was never finished.
+*/
+func writeClamdStream(conn net.Conn, content io.Reader) (readErr error, writeErr error) {
+	writer := bufio.NewWriterSize(conn, clamdChunkSize+4)
+
+	if _, writeErr = writer.WriteString("zINSTREAM\x00"); writeErr != nil {
+		return nil, writeErr
+	}
+
+	chunk := make([]byte, clamdChunkSize)
+
+	for {
+		n, err := io.ReadFull(content, chunk)
+
+		if n > 0 {
+			if writeErr = binary.Write(writer, binary.BigEndian, uint32(n)); writeErr != nil {
+				return nil, writeErr
+			}
+
+			if _, writeErr = writer.Write(chunk[:n]); writeErr != nil {
+				return nil, writeErr
+			}
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+
+		if err != nil {
+			return err, nil
+		}
+	}
+
+	if writeErr = binary.Write(writer, binary.BigEndian, uint32(0)); writeErr != nil {
+		return nil, writeErr
+	}
+
+	return nil, writer.Flush()
+}
+
+/*
+readClamdReply reads a null terminated reply, as requested by the "z"
+prefix on commands.
+*/
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("error reading reply from clamd: %w", err)
+	}
+
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
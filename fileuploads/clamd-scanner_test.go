@@ -0,0 +1,208 @@
+package fileuploads
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+fakeClamd is a minimal stand-in for clamd. It understands PING and
+INSTREAM, reports streams containing the EICAR marker as infected, and
+refuses streams longer than maxStream the way clamd does.
+*/
+type fakeClamd struct {
+	maxStream int
+	hang      bool
+	received  chan string
+}
+
+func newFakeClamd(t *testing.T, network string, fake *fakeClamd) string {
+	t.Helper()
+
+	address := "127.0.0.1:0"
+
+	if network == "unix" {
+		address = filepath.Join(t.TempDir(), "clamd.sock")
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go fake.serve(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	command, err := reader.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	if command == "zPING\x00" {
+		conn.Write([]byte("PONG\x00"))
+		return
+	}
+
+	if command != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	stream := []byte{}
+
+	for {
+		var length uint32
+
+		if err = binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return
+		}
+
+		if length == 0 {
+			break
+		}
+
+		chunk := make([]byte, length)
+
+		if _, err = io.ReadFull(reader, chunk); err != nil {
+			return
+		}
+
+		stream = append(stream, chunk...)
+
+		if f.maxStream > 0 && len(stream) > f.maxStream {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+
+	if f.received != nil {
+		f.received <- string(stream)
+	}
+
+	if f.hang {
+		time.Sleep(time.Second)
+		return
+	}
+
+	if strings.Contains(string(stream), eicarMarker) {
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+func TestClamdScanner(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			fake := &fakeClamd{received: make(chan string, 2)}
+			scanner := NewClamdScanner(network, newFakeClamd(t, network, fake))
+			ctx := context.Background()
+
+			if err := scanner.Ping(ctx); err != nil {
+				t.Errorf("Ping failed: %v", err)
+			}
+
+			// Larger than one chunk, so the stream is split.
+			clean := strings.Repeat("a", clamdChunkSize+10)
+
+			if err := scanner.Scan(ctx, strings.NewReader(clean)); err != nil {
+				t.Errorf("Expected clean content to pass, got %v", err)
+			}
+
+			if received := <-fake.received; received != clean {
+				t.Errorf("Expected clamd to receive %d bytes, got %d", len(clean), len(received))
+			}
+
+			err := scanner.Scan(ctx, strings.NewReader("X5O!P%@AP "+eicarMarker))
+
+			var infected *InfectedError
+
+			if !errors.As(err, &infected) || infected.Signature != "Eicar-Test-Signature" {
+				t.Errorf("Expected an InfectedError naming the signature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClamdScannerErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fake     *fakeClamd
+		timeout  time.Duration
+		content  string
+		expected string
+	}{
+		{"size limit", &fakeClamd{maxStream: 10}, time.Second, strings.Repeat("a", clamdChunkSize*4), "clamd returned 'INSTREAM size limit exceeded. ERROR'"},
+		{"timeout", &fakeClamd{hang: true}, 50 * time.Millisecond, "hello", "error reading reply from clamd"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scanner := NewClamdScanner("tcp", newFakeClamd(t, "tcp", tc.fake))
+			scanner.Timeout = tc.timeout
+
+			err := scanner.Scan(context.Background(), strings.NewReader(tc.content))
+
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing '%s', got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestClamdScannerUnreachable(t *testing.T) {
+	scanner := NewClamdScanner("unix", filepath.Join(t.TempDir(), "missing.sock"))
+	req := createMultipartRequest(t, "upload", "notes.txt", "hello")
+
+	err := ReadUploadedFile("upload", req, func(file FileUpload, options *UploadOptions) error {
+		return nil
+	}, WithScanner(scanner))
+
+	if !errors.Is(err, ErrScanFailed) || !strings.Contains(err.Error(), "error connecting to clamd") {
+		t.Errorf("Expected ErrScanFailed, got %v", err)
+	}
+}
+
+func TestClamdScannerContextCancelled(t *testing.T) {
+	scanner := NewClamdScanner("tcp", newFakeClamd(t, "tcp", &fakeClamd{hang: true}))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	if err := scanner.Scan(ctx, strings.NewReader("hello")); err == nil {
+		t.Errorf("Expected an error when the context is cancelled")
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the scan to stop when the context was cancelled, took %v", elapsed)
+	}
+}
@@ -0,0 +1,293 @@
+package fileuploads
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrFileTypeNotAllowed = errors.New("file type is not allowed")
+)
+
+/*
+sniffLength is how much of a file is inspected to detect its type. It is
+larger than the 512 bytes http.DetectContentType looks at so that the
+first entries of an Office document's zip container can be seen.
+*/
+const sniffLength = 8 << 10
+
+const (
+	typeDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	typeXlsx = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	typePptx = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	typeOle  = "application/x-ole-storage"
+)
+
+var (
+	oleSignature       = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	zipLocalFileHeader = []byte("PK\x03\x04")
+)
+
+/*
+extensionTypes lists the detected content types that are acceptable for a
+file extension. Files with an extension in this table whose content is
+detected as something else are rejected as a mismatch.
+*/
+var extensionTypes = map[string][]string{
+	".png":  {"image/png"},
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+	".bmp":  {"image/bmp"},
+	".ico":  {"image/x-icon"},
+	".pdf":  {"application/pdf"},
+	".zip":  {"application/zip"},
+	".gz":   {"application/x-gzip"},
+	".docx": {typeDocx},
+	".xlsx": {typeXlsx},
+	".pptx": {typePptx},
+	".doc":  {"application/msword"},
+	".xls":  {"application/vnd.ms-excel"},
+	".ppt":  {"application/vnd.ms-powerpoint"},
+	".csv":  {"text/csv"},
+	".tsv":  {"text/tab-separated-values"},
+	".txt":  {"text/plain"},
+	".json": {"application/json"},
+	".xml":  {"text/xml"},
+	".mp3":  {"audio/mpeg"},
+	".mp4":  {"video/mp4"},
+}
+
+/*
+textExtensionTypes refines a plain text detection using the extension,
+since formats such as CSV have no magic bytes.
+*/
+var textExtensionTypes = map[string]string{
+	".csv":  "text/csv",
+	".tsv":  "text/tab-separated-values",
+	".json": "application/json",
+}
+
+/*
+oleExtensionTypes names legacy Office documents, which share a single OLE
+container signature.
+*/
+var oleExtensionTypes = map[string]string{
+	".doc": "application/msword",
+	".xls": "application/vnd.ms-excel",
+	".ppt": "application/vnd.ms-powerpoint",
+}
+
+/*
+DetectContentType determines the real type of a file from its first bytes,
+ignoring whatever the client declared. On top of http.DetectContentType it
+recognizes Office documents, both the zip based formats (docx, xlsx, pptx)
+and the legacy OLE formats, and text formats such as CSV by extension.
+Parameters such as charset are not included in the result.
+
+head holds the beginning of the file. When file is not nil it is used to
+read a zip container's directory, which is more accurate than inspecting
+head alone.
+*/
+func DetectContentType(head []byte, ext string, file io.ReaderAt, size int64) string {
+	ext = strings.ToLower(ext)
+
+	if bytes.HasPrefix(head, oleSignature) {
+		if contentType, ok := oleExtensionTypes[ext]; ok {
+			return contentType
+		}
+
+		return typeOle
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+
+	switch contentType {
+	case "application/zip":
+		return detectZipType(head, file, size)
+
+	case "text/plain":
+		if textType, ok := textExtensionTypes[ext]; ok {
+			return textType
+		}
+	}
+
+	return contentType
+}
+
+/*
+WithAllowedTypes restricts uploads to files whose detected content type
+is one of the given types. A type may end in a wildcard subtype, such as
+"image/*". Setting allowed types also rejects files whose extension does
+not match their content.
+*/
+func WithAllowedTypes(contentTypes ...string) UploadOption {
+	return func(o *UploadOptions) {
+		o.AllowedTypes = append(o.AllowedTypes, contentTypes...)
+	}
+}
+
+/*
+WithAllowedExtensions restricts uploads to files with one of the given
+extensions, compared case insensitively. Setting allowed extensions also
+rejects files whose extension does not match their content.
+*/
+func WithAllowedExtensions(extensions ...string) UploadOption {
+	return func(o *UploadOptions) {
+		for _, ext := range extensions {
+			ext = strings.ToLower(ext)
+
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+
+			o.AllowedExtensions = append(o.AllowedExtensions, ext)
+		}
+	}
+}
+
+/*
+detectUploadedFileType sniffs a seekable uploaded file, records the
+detected type and checks it against the allow-lists.
+*/
+func detectUploadedFileType(file *FileUpload, opts *UploadOptions) error {
+	var (
+		err error
+		n   int
+	)
+
+	head := make([]byte, min(sniffLength, max(file.Size, 0)))
+
+	if n, err = file.File.ReadAt(head, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("error reading uploaded file '%s': %w", file.FileName, err)
+	}
+
+	file.DetectedContentType = DetectContentType(head[:n], file.Ext, file.File, file.Size)
+	return checkFileType(file.FileName, file.Ext, file.DetectedContentType, opts)
+}
+
+/*
+detectStreamType reads the beginning of a stream to detect its type and
+returns a reader that still yields the entire stream. When reading fails,
+the type is detected from what was read, and the error is returned along
+with a reader that yields those bytes before failing again.
+*/
+func detectStreamType(reader io.Reader, ext string) (string, io.Reader, error) {
+	var (
+		err error
+		n   int
+	)
+
+	head := make([]byte, sniffLength)
+
+	if n, err = io.ReadFull(reader, head); err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+
+	head = head[:n]
+	return DetectContentType(head, ext, nil, 0), io.MultiReader(bytes.NewReader(head), reader), err
+}
+
+func checkFileType(fileName, ext, contentType string, opts *UploadOptions) error {
+	if len(opts.AllowedTypes) == 0 && len(opts.AllowedExtensions) == 0 {
+		return nil
+	}
+
+	ext = strings.ToLower(ext)
+
+	if len(opts.AllowedExtensions) > 0 && !slices.Contains(opts.AllowedExtensions, ext) {
+		return fmt.Errorf("file '%s' has extension '%s', which is not allowed: %w", fileName, ext, ErrFileTypeNotAllowed)
+	}
+
+	if expected, ok := extensionTypes[ext]; ok && !slices.Contains(expected, contentType) {
+		return fmt.Errorf("file '%s' has extension '%s' but contains '%s': %w", fileName, ext, contentType, ErrFileTypeNotAllowed)
+	}
+
+	if len(opts.AllowedTypes) > 0 && !slices.ContainsFunc(opts.AllowedTypes, func(allowed string) bool {
+		return matchContentType(allowed, contentType)
+	}) {
+		return fmt.Errorf("file '%s' contains '%s', which is not allowed: %w", fileName, contentType, ErrFileTypeNotAllowed)
+	}
+
+	return nil
+}
+
+func matchContentType(allowed, contentType string) bool {
+	allowed = strings.ToLower(strings.TrimSpace(allowed))
+
+	if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+		return strings.HasPrefix(contentType, prefix+"/")
+	}
+
+	return allowed == contentType
+}
+
+/*
+detectZipType tells Office documents apart from plain zip archives by the
+names of their entries. The zip directory is read when a file is
+available, otherwise the local file headers within head are inspected.
+*/
+func detectZipType(head []byte, file io.ReaderAt, size int64) string {
+	if file != nil {
+		if zipReader, err := zip.NewReader(file, size); err == nil {
+			for _, entry := range zipReader.File {
+				if contentType := officeZipEntryType(entry.Name); contentType != "" {
+					return contentType
+				}
+			}
+
+			return "application/zip"
+		}
+	}
+
+	for offset := 0; ; {
+		index := bytes.Index(head[offset:], zipLocalFileHeader)
+
+		if index < 0 {
+			break
+		}
+
+		start := offset + index
+
+		if start+30 > len(head) {
+			break
+		}
+
+		nameEnd := start + 30 + int(binary.LittleEndian.Uint16(head[start+26:]))
+
+		if nameEnd > len(head) {
+			break
+		}
+
+		if contentType := officeZipEntryType(string(head[start+30 : nameEnd])); contentType != "" {
+			return contentType
+		}
+
+		offset = start + len(zipLocalFileHeader)
+	}
+
+	return "application/zip"
+}
+
+func officeZipEntryType(name string) string {
+	switch {
+	case strings.HasPrefix(name, "word/"):
+		return typeDocx
+
+	case strings.HasPrefix(name, "xl/"):
+		return typeXlsx
+
+	case strings.HasPrefix(name, "ppt/"):
+		return typePptx
+	}
+
+	return ""
+}
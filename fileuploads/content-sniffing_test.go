@@ -0,0 +1,150 @@
+package fileuploads
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+var (
+	pngContent = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	pdfContent = "%PDF-1.7\n1 0 obj"
+)
+
+func createZipContent(t *testing.T, names ...string) []byte {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+
+	for _, name := range names {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+
+		io.WriteString(w, "<xml/>")
+	}
+
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func TestDetectContentType(t *testing.T) {
+	docx := createZipContent(t, "[Content_Types].xml", "_rels/.rels", "word/document.xml")
+	xlsx := createZipContent(t, "[Content_Types].xml", "xl/workbook.xml")
+	plainZip := createZipContent(t, "notes.txt")
+
+	testCases := []struct {
+		name     string
+		content  []byte
+		ext      string
+		seekable bool
+		expected string
+	}{
+		{"Png", []byte(pngContent), ".png", true, "image/png"},
+		{"Jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), ".jpg", true, "image/jpeg"},
+		{"Pdf", []byte(pdfContent), ".pdf", true, "application/pdf"},
+		{"Csv", []byte("name,age\nadam,42\n"), ".CSV", true, "text/csv"},
+		{"Text", []byte("just some notes"), ".txt", true, "text/plain"},
+		{"LegacyWord", append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, 0, 0), ".doc", true, "application/msword"},
+		{"OleUnknownExtension", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, ".bin", true, "application/x-ole-storage"},
+		{"Docx", docx, ".docx", true, typeDocx},
+		{"DocxStreamed", docx, ".docx", false, typeDocx},
+		{"XlsxStreamed", xlsx, ".xlsx", false, typeXlsx},
+		{"Zip", plainZip, ".zip", true, "application/zip"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var file io.ReaderAt
+
+			if tc.seekable {
+				file = bytes.NewReader(tc.content)
+			}
+
+			got := DetectContentType(tc.content, tc.ext, file, int64(len(tc.content)))
+
+			if got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadUploadedFileAllowedTypes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		content  string
+		options  []UploadOption
+		expected error
+	}{
+		{"Allowed", "photo.png", pngContent, []UploadOption{WithAllowedTypes("image/png", "application/pdf")}, nil},
+		{"Wildcard", "photo.png", pngContent, []UploadOption{WithAllowedTypes("image/*")}, nil},
+		{"TypeNotAllowed", "document.pdf", pdfContent, []UploadOption{WithAllowedTypes("image/png")}, ErrFileTypeNotAllowed},
+		{"ExtensionMismatch", "photo.png", pdfContent, []UploadOption{WithAllowedTypes("image/png", "application/pdf")}, ErrFileTypeNotAllowed},
+		{"ExtensionAllowed", "Photo.PNG", pngContent, []UploadOption{WithAllowedExtensions("png", ".jpg")}, nil},
+		{"ExtensionNotAllowed", "script.sh", "#!/bin/sh", []UploadOption{WithAllowedExtensions(".png")}, ErrFileTypeNotAllowed},
+		{"NoAllowList", "photo.png", pdfContent, nil, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createMultipartRequest(t, "upload", tc.fileName, tc.content)
+			detected := ""
+
+			err := ReadUploadedFile("upload", req, func(file FileUpload, options *UploadOptions) error {
+				detected = file.DetectedContentType
+				return nil
+			}, tc.options...)
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if err == nil && detected == "" {
+				t.Error("Expected DetectedContentType to be populated")
+			}
+		})
+	}
+}
+
+func TestStreamUploadedFilesAllowedTypes(t *testing.T) {
+	t.Run("Allowed", func(t *testing.T) {
+		req := createStreamingRequest(t, testPart{fieldName: "file", fileName: "photo.png", content: pngContent})
+
+		_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+			content, err := io.ReadAll(part.Reader)
+
+			if string(content) != pngContent {
+				t.Errorf("Expected the full content after sniffing, got %q", content)
+			}
+
+			if part.DetectedContentType != "image/png" {
+				t.Errorf("Expected 'image/png', got '%s'", part.DetectedContentType)
+			}
+
+			return err
+		}, WithAllowedTypes("image/png"))
+
+		if err != nil {
+			t.Fatalf("StreamUploadedFiles failed: %v", err)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		req := createStreamingRequest(t, testPart{fieldName: "file", fileName: "photo.png", content: "<html><script></script></html>"})
+
+		_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+			t.Fatal("Callback was called for a disallowed file")
+			return nil
+		}, WithAllowedTypes("image/png"))
+
+		if !errors.Is(err, ErrFileTypeNotAllowed) {
+			t.Errorf("Expected ErrFileTypeNotAllowed, got %v", err)
+		}
+	})
+}
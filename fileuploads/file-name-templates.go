@@ -0,0 +1,197 @@
+package fileuploads
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+/*
+WithTemplateData makes caller supplied values, such as a user ID,
+available in the destination file name template. Keys that clash with the
+built in template values are ignored.
+*/
+func WithTemplateData(data map[string]any) UploadOption {
+	return func(o *UploadOptions) {
+		if o.TemplateData == nil {
+			o.TemplateData = map[string]any{}
+		}
+
+		for key, value := range data {
+			o.TemplateData[key] = value
+		}
+	}
+}
+
+/*
+templateUsesChecksums reports whether a file name template refers to any
+of the checksums, which must then be computed before the file is saved.
+*/
+func templateUsesChecksums(destFileNameTemplate string) bool {
+	for _, name := range []string{".sha256", ".md5", ".sha1", ".crc32c"} {
+		if strings.Contains(destFileNameTemplate, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+DestinationFileName crafts an object name for an uploaded file using the
+provided template, the same way UploadFile does. It is useful when saving
+a file handed to a ReadUploadedFile callback. Names that would escape the
+storage root are rejected. The name is built from the exported fields of
+file, so a FileUpload created by the caller works too, and nil options
+mean the defaults.
+*/
+func DestinationFileName(file FileUpload, destFileNameTemplate string, options *UploadOptions) (string, error) {
+	var (
+		err error
+		tt  *template.Template
+	)
+
+	if options == nil {
+		options = newUploadOptions(nil)
+	}
+
+	destFileName := strings.Builder{}
+	baseFileName := SanitizeFileName(file.FileName)
+
+	templateData := map[string]any{}
+
+	for key, value := range options.TemplateData {
+		templateData[key] = value
+	}
+
+	builtIns := map[string]any{
+		// fileName is exactly what the client sent, and is only safe in a
+		// name once sanitized. Templates should use baseFileName instead.
+		"fileName":     file.FileName,
+		"baseFileName": baseFileName,
+		"baseName":     strings.TrimSuffix(baseFileName, filepath.Ext(baseFileName)),
+		"ext":          filepath.Ext(baseFileName),
+		"size":         file.Size,
+		"randomString": randomString(options.RandomStringSize),
+		"sha256":       file.Checksums.SHA256,
+	}
+
+	for key, value := range map[string]string{"md5": file.Checksums.MD5, "sha1": file.Checksums.SHA1, "crc32c": file.Checksums.CRC32C} {
+		if value != "" {
+			builtIns[key] = value
+		}
+	}
+
+	for key, value := range builtIns {
+		templateData[key] = value
+	}
+
+	if tt, err = template.New("fileupload").Option("missingkey=error").Funcs(templateFuncs(time.Now().UTC())).Parse(destFileNameTemplate); err != nil {
+		return "", fmt.Errorf("error parsing destination file name template: %w", err)
+	}
+
+	if err = tt.Execute(&destFileName, templateData); err != nil {
+		return "", fmt.Errorf("error executing destination file name template: %w", err)
+	}
+
+	// Security check to ensure the file path stays within the storage root.
+	// Storages also check names, and LocalStorage refuses to follow symlinks out.
+	if !filepath.IsLocal(destFileName.String()) {
+		return "", fmt.Errorf("invalid destination file path attempted: %s", destFileName.String())
+	}
+
+	return filepath.ToSlash(filepath.Clean(destFileName.String())), nil
+}
+
+/*
+templateFuncs returns the functions available in destination file name
+templates. uploadTime is used by date so that every call within one
+template sees the same moment.
+*/
+func templateFuncs(uploadTime time.Time) template.FuncMap {
+	return template.FuncMap{
+		"date": func(layout string) string {
+			return uploadTime.Format(layout)
+		},
+		"uuid":     uuidV4,
+		"uuidv7":   func() string { return uuidV7(time.Now()) },
+		"slug":     slugify,
+		"sanitize": SanitizeFileName,
+		"lower":    strings.ToLower,
+		"upper":    strings.ToUpper,
+		"prefix": func(length int, value string) string {
+			if length < 0 || length >= len(value) {
+				return value
+			}
+
+			return value[:length]
+		},
+	}
+}
+
+/*
+uuidV4 returns a random (version 4) UUID.
+*/
+func uuidV4() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return formatUUID(b)
+}
+
+/*
+uuidV7 returns a time ordered (version 7) UUID, whose first 48 bits are
+the Unix time in milliseconds. Names built from them sort by upload time.
+*/
+func uuidV7(now time.Time) string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[6:])
+
+	binary.BigEndian.PutUint64(b[:8], uint64(now.UnixMilli())<<16|uint64(binary.BigEndian.Uint16(b[6:8])))
+
+	b[6] = (b[6] & 0x0f) | 0x70
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return formatUUID(b)
+}
+
+func formatUUID(b [16]byte) string {
+	encoded := hex.EncodeToString(b[:])
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:]
+}
+
+/*
+slugify lower cases a value and replaces every run of characters other
+than letters and digits with a single dash.
+*/
+func slugify(value string) string {
+	result := strings.Builder{}
+	pendingDash := false
+
+	for _, r := range strings.ToLower(value) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingDash = result.Len() > 0
+			continue
+		}
+
+		if pendingDash {
+			result.WriteByte('-')
+			pendingDash = false
+		}
+
+		result.WriteRune(r)
+	}
+
+	return result.String()
+}
@@ -0,0 +1,102 @@
+package fileuploads
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDestinationFileNameTemplates(t *testing.T) {
+	uploadMonth := time.Now().UTC().Format("2006/01")
+
+	testCases := []struct {
+		name     string
+		template string
+		fileName string
+		expected string
+	}{
+		{"Date", `{{date "2006/01"}}/{{.baseFileName}}`, "report.pdf", "^" + uploadMonth + "/report.pdf$"},
+		{"UUID", `{{uuid}}{{.ext}}`, "report.pdf", `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.pdf$`},
+		{"UUIDv7", `{{uuidv7}}{{.ext}}`, "report.pdf", `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.pdf$`},
+		{"Slug", `{{slug .baseName}}{{lower .ext}}`, "Quarterly Report (Final).PDF", "^quarterly-report-final.pdf$"},
+		{"Sanitize", `{{sanitize .fileName}}`, `C:\temp\bad:name?.txt`, "^bad_name_.txt$"},
+		{"Upper", `{{upper .baseFileName}}`, "report.pdf", "^REPORT.PDF$"},
+		{"HashPrefix", `{{prefix 2 .sha256}}/{{.sha256}}{{.ext}}`, "hello.txt", "^b9/" + helloWorldSHA256 + `\.txt$`},
+		{"CallerData", `{{.userID}}/{{.baseFileName}}`, "report.pdf", "^user-42/report.pdf$"},
+		{"BuiltInsWin", `{{.ext}}`, "report.pdf", `^\.pdf$`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createMultipartRequest(t, "upload", tc.fileName, "hello world")
+			storage := NewMemoryStorage()
+
+			upload, err := UploadFile(storage, "upload", req, tc.template, WithTemplateData(map[string]any{"userID": "user-42", "ext": ".exe"}))
+			if err != nil {
+				t.Fatalf("UploadFile failed: %v", err)
+			}
+
+			if !regexp.MustCompile(tc.expected).MatchString(upload.SavedFile) {
+				t.Errorf("Expected a name matching '%s', got '%s'", tc.expected, upload.SavedFile)
+			}
+		})
+	}
+}
+
+func TestDestinationFileNameCallerUpload(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  *UploadOptions
+		expected string
+	}{
+		{"DefaultOptions", nil, "notes-3.txt"},
+		{"WithOptions", newUploadOptions([]UploadOption{WithTemplateData(map[string]any{"userID": "user-42"})}), "notes-3.txt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, err := DestinationFileName(FileUpload{FileName: "../notes.txt", Size: 3}, "{{.baseName}}-{{.size}}{{.ext}}", tc.options)
+			if err != nil {
+				t.Fatalf("DestinationFileName failed: %v", err)
+			}
+
+			if name != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, name)
+			}
+		})
+	}
+}
+
+func TestUUIDv7Ordering(t *testing.T) {
+	first := uuidV7(time.UnixMilli(1_700_000_000_000))
+	second := uuidV7(time.UnixMilli(1_700_000_000_001))
+
+	if !strings.HasPrefix(first, "018bcfe5-6800-7") {
+		t.Errorf("Expected the timestamp in the first 48 bits, got '%s'", first)
+	}
+
+	if first >= second {
+		t.Errorf("Expected '%s' to sort before '%s'", first, second)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Hello World", "hello-world"},
+		{"  --Crème brûlée--  ", "crème-brûlée"},
+		{"a___b...c", "a-b-c"},
+		{"!!!", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := slugify(tc.input); got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+}
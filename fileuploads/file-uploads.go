@@ -1,56 +1,95 @@
 package fileuploads
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
-	"os"
 	"path/filepath"
-	"strings"
-	"text/template"
 	"time"
 )
 
+var (
+	ErrFileTooLarge    = errors.New("file exceeds the maximum allowed size")
+	ErrFieldTooLarge   = errors.New("form field exceeds the maximum allowed size")
+	ErrRequestTooLarge = errors.New("request exceeds the maximum allowed size")
+	ErrTooManyFiles    = errors.New("request contains too many files")
+	ErrTooManyFields   = errors.New("request contains too many form fields")
+	ErrFileNotConsumed = errors.New("uploaded file was not read to the end")
+)
+
+/*
+FileUpload describes an uploaded file. File is only open for the duration
+of the callback. Header is declared by the client and cannot be trusted,
+while DetectedContentType is sniffed from the file's content. When several
+files are read at once, Err holds the reason a single file was rejected or
+failed to process.
+*/
 type FileUpload struct {
 	File multipart.File
 	info *multipart.FileHeader
 
-	FileName      string
-	Size          int64
-	Header        textproto.MIMEHeader
-	Ext           string
-	SavedFile     string
-	SavedFilePath string
+	FieldName           string
+	FileName            string
+	Size                int64
+	Header              textproto.MIMEHeader
+	Ext                 string
+	DetectedContentType string
+	Checksums           Checksums
+	SavedFile           string
+	SavedFilePath       string
+	Err                 error
 }
 
 type UploadOptions struct {
-	MaxFileSize      int64
-	RandomStringSize int
+	MaxFileSize       int64
+	MaxFieldSize      int64
+	MaxFormFields     int
+	MaxFormSize       int64
+	MaxRequestSize    int64
+	MaxFiles          int
+	RandomStringSize  int
+	AllowedTypes      []string
+	AllowedExtensions []string
+	Collision         CollisionPolicy
+	FileMode          fs.FileMode
+	DirMode           fs.FileMode
+	CreateDirs        bool
+	Checksums         []ChecksumAlgorithm
+	VerifyDigest      bool
+	TemplateData      map[string]any
+	Expiration        time.Duration
+	Scanner           Scanner
+	ScanFailOpen      bool
 }
 
 type UploadOption func(o *UploadOptions)
 
 func ReadUploadedFile(fieldName string, r *http.Request, callback func(file FileUpload, options *UploadOptions) error, options ...UploadOption) error {
+	return readUploadedFile(fieldName, r, func(file *FileUpload, options *UploadOptions) error {
+		if err := computeChecksums(file, options); err != nil {
+			return err
+		}
+
+		return callback(*file, options)
+	}, options...)
+}
+
+func readUploadedFile(fieldName string, r *http.Request, process func(file *FileUpload, options *UploadOptions) error, options ...UploadOption) error {
 	var (
 		err          error
 		uploadedFile multipart.File
 		info         *multipart.FileHeader
 	)
 
-	opts := &UploadOptions{
-		MaxFileSize:      10 << 20,
-		RandomStringSize: 10,
-	}
+	opts := newUploadOptions(options)
 
-	for _, opt := range options {
-		opt(opts)
-	}
-
-	if err = r.ParseMultipartForm(opts.MaxFileSize); err != nil {
-		return fmt.Errorf("error parsing form data: %w", err)
+	if err = parseMultipartForm(r, opts); err != nil {
+		return err
 	}
 
 	if uploadedFile, info, err = r.FormFile(fieldName); err != nil {
@@ -60,87 +99,132 @@ func ReadUploadedFile(fieldName string, r *http.Request, callback func(file File
 	defer uploadedFile.Close()
 
 	if info.Size > opts.MaxFileSize {
-		return fmt.Errorf("file size of %d bytes exceeds the limit of %d bytes", info.Size, opts.MaxFileSize)
+		return fmt.Errorf("file size of %d bytes exceeds the limit of %d bytes: %w", info.Size, opts.MaxFileSize, ErrFileTooLarge)
 	}
 
-	callbackData := FileUpload{
-		File: uploadedFile,
-		info: info,
+	callbackData := newFileUpload(fieldName, info)
+	callbackData.File = uploadedFile
+
+	if err = inspectUploadedFile(r.Context(), &callbackData, opts); err != nil {
+		return err
 	}
 
-	return callback(callbackData, opts)
+	return process(&callbackData, opts)
 }
 
+/*
+UploadFileToDir reads a file from a request and saves it to destDir, using
+a template to generate the file name. SavedFile is set to the file's path
+within destDir and SavedFilePath to its absolute path. The file is written
+atomically and, by default, an existing file with the same name is never
+replaced; see WithCollisionPolicy.
+*/
 func UploadFileToDir(fieldName string, r *http.Request, destDir string, destFileNameTemplate string, options ...UploadOption) (FileUpload, error) {
-	result := &FileUpload{}
-
-	err := ReadUploadedFile(fieldName, r, func(file FileUpload, options *UploadOptions) error {
-		var (
-			err      error
-			tt       *template.Template
-			tempFile *os.File
-		)
-
-		result = &file
-
-		/*
-		 * Craft a destination file name using the provided template
-		 */
-		destFileName := strings.Builder{}
-
-		templateData := map[string]any{
-			"fileName":     file.info.Filename,
-			"baseFileName": filepath.Base(file.info.Filename),
-			"ext":          filepath.Ext(file.info.Filename),
-			"size":         file.info.Size,
-			"randomString": randomString(options.RandomStringSize),
-		}
+	result, err := UploadFile(newLocalStorage(destDir, options), fieldName, r, destFileNameTemplate, options...)
 
-		if tt, err = template.New("fileupload").Option("missingkey=error").Parse(destFileNameTemplate); err != nil {
-			return fmt.Errorf("error parsing destination file name template: %w", err)
-		}
+	if result.SavedFile != "" {
+		result.SavedFile = filepath.Join(destDir, filepath.FromSlash(result.SavedFile))
+	}
 
-		if err = tt.Execute(&destFileName, templateData); err != nil {
-			return fmt.Errorf("error executing destination file name template: %w", err)
-		}
+	return result, err
+}
 
-		/*
-		 * Move the uploaded file to the destination directory
-		 */
-		finalPath := filepath.Join(destDir, destFileName.String())
+func WithMaxFileSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFileSize = size
+	}
+}
 
-		// Security check to ensure the file path is within the destination directory
-		if !strings.HasPrefix(finalPath, filepath.Clean(destDir)) {
-			return fmt.Errorf("invalid destination file path attempted: %s", destFileName.String())
-		}
+/*
+WithMaxFieldSize overrides the maximum size of a regular form field read by
+StreamUploadedFiles.
+*/
+func WithMaxFieldSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFieldSize = size
+	}
+}
 
-		if tempFile, err = os.Create(finalPath); err != nil {
-			return fmt.Errorf("error creating file '%s': %w", destFileName.String(), err)
-		}
+/*
+WithMaxFormFields limits how many regular form fields StreamUploadedFiles
+accepts. Zero means no limit.
+*/
+func WithMaxFormFields(count int) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFormFields = count
+	}
+}
 
-		defer tempFile.Close()
+/*
+WithMaxFormSize limits the combined size of the regular form fields read
+by StreamUploadedFiles. Zero means no limit.
+*/
+func WithMaxFormSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFormSize = size
+	}
+}
 
-		if _, err = io.Copy(tempFile, file.File); err != nil {
-			tempFile.Close()
-			return fmt.Errorf("error copying uploaded file '%s' to temporary file '%s': %w", file.info.Filename, destFileName.String(), err)
-		}
+/*
+WithMaxRequestSize limits the size of the entire request body. Zero means
+no limit, which is the default except for StreamUploadedFiles, where it is
+100MB.
+*/
+func WithMaxRequestSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxRequestSize = size
+	}
+}
 
-		if absolutePath, err := filepath.Abs(tempFile.Name()); err == nil {
-			result.SavedFilePath = absolutePath
-		}
+/*
+WithMaxFiles limits how many files ReadUploadedFiles and UploadFilesToDir
+accept in a single request. Zero means no limit.
+*/
+func WithMaxFiles(count int) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFiles = count
+	}
+}
 
-		result.Ext = filepath.Ext(tempFile.Name())
-		result.SavedFile = tempFile.Name()
+/*
+WithCollisionPolicy decides what UploadFileToDir and UploadFilesToDir do
+when a file already exists with the generated name. The default is
+CollisionFail. UploadFile and UploadFiles leave this to the Storage they
+are given, so set LocalStorage.Collision there instead.
+*/
+func WithCollisionPolicy(policy CollisionPolicy) UploadOption {
+	return func(o *UploadOptions) {
+		o.Collision = policy
+	}
+}
 
-		return nil
-	}, options...)
+/*
+WithFileMode sets the permissions of files saved by UploadFileToDir and
+UploadFilesToDir. The default is 0644.
+*/
+func WithFileMode(mode fs.FileMode) UploadOption {
+	return func(o *UploadOptions) {
+		o.FileMode = mode
+	}
+}
 
-	return *result, err
+/*
+WithDirMode sets the permissions of directories created because of
+WithCreateDirs. The default is 0755.
+*/
+func WithDirMode(mode fs.FileMode) UploadOption {
+	return func(o *UploadOptions) {
+		o.DirMode = mode
+	}
 }
 
-func WithMaxFileSize(size int64) UploadOption {
+/*
+WithCreateDirs creates any missing subdirectories produced by the file
+name template, instead of failing.
+*/
+func WithCreateDirs() UploadOption {
 	return func(o *UploadOptions) {
-		o.MaxFileSize = size
+		o.CreateDirs = true
 	}
 }
 
@@ -150,6 +234,82 @@ func WithRandomStringSize(size int) UploadOption {
 	}
 }
 
+func newUploadOptions(options []UploadOption) *UploadOptions {
+	result := &UploadOptions{
+		MaxFileSize:      10 << 20,
+		MaxFieldSize:     1 << 20,
+		MaxFormFields:    1000,
+		MaxFormSize:      10 << 20,
+		RandomStringSize: 10,
+		Collision:        CollisionFail,
+		FileMode:         0o644,
+		DirMode:          0o755,
+	}
+
+	for _, opt := range options {
+		opt(result)
+	}
+
+	return result
+}
+
+func newLocalStorage(dir string, options []UploadOption) *LocalStorage {
+	opts := newUploadOptions(options)
+	storage := NewLocalStorage(dir)
+
+	storage.Collision = opts.Collision
+	storage.FileMode = opts.FileMode
+	storage.DirMode = opts.DirMode
+	storage.CreateDirs = opts.CreateDirs
+
+	return storage
+}
+
+/*
+inspectUploadedFile detects the type of an open uploaded file, checks it
+against the allow-lists, and scans it for malware. Checksums are left to
+the caller, which computes them while it reads the file.
+*/
+func inspectUploadedFile(ctx context.Context, file *FileUpload, opts *UploadOptions) error {
+	if err := detectUploadedFileType(file, opts); err != nil {
+		return err
+	}
+
+	return scanUploadedFile(ctx, file, opts)
+}
+
+func newFileUpload(fieldName string, info *multipart.FileHeader) FileUpload {
+	return FileUpload{
+		info:      info,
+		FieldName: fieldName,
+		FileName:  info.Filename,
+		Size:      info.Size,
+		Header:    info.Header,
+		Ext:       filepath.Ext(info.Filename),
+	}
+}
+
+func parseMultipartForm(r *http.Request, opts *UploadOptions) error {
+	var (
+		err         error
+		maxBytesErr *http.MaxBytesError
+	)
+
+	if opts.MaxRequestSize > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, opts.MaxRequestSize)
+	}
+
+	if err = r.ParseMultipartForm(opts.MaxFileSize); err != nil {
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("request exceeds the limit of %d bytes: %w", maxBytesErr.Limit, ErrRequestTooLarge)
+		}
+
+		return fmt.Errorf("error parsing form data: %w", err)
+	}
+
+	return nil
+}
+
 func randomString(length int) string {
 	characters := "abcdefghijklmnopqrstuvwxyz0123456789"
 	b := make([]byte, length)
@@ -2,6 +2,7 @@ package fileuploads
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -52,6 +53,10 @@ func TestReadUploadedFileSuccess(t *testing.T) {
 			t.Errorf("Expected filename '%s', got '%s'", fileName, file.info.Filename)
 		}
 
+		if file.FileName != fileName || file.FieldName != fieldName || file.Ext != ".txt" || file.Size != int64(len(fileContent)) {
+			t.Errorf("Expected file details to be populated, got %+v", file)
+		}
+
 		content, err := io.ReadAll(file.File)
 		if err != nil {
 			return fmt.Errorf("could not read file content from callback: %w", err)
@@ -105,6 +110,47 @@ func TestReadUploadedFileExceedsMaxSize(t *testing.T) {
 	}
 }
 
+func TestReadUploadedFileLargerThanStreamingLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping a 120MB upload in short mode")
+	}
+
+	const size = 120 << 20
+
+	body, bodyWriter := io.Pipe()
+	writer := multipart.NewWriter(bodyWriter)
+
+	go func() {
+		part, err := writer.CreateFormFile("testfile", "large.bin")
+		chunk := make([]byte, 1<<20)
+
+		for written := 0; err == nil && written < size; written += len(chunk) {
+			_, err = part.Write(chunk)
+		}
+
+		if err == nil {
+			err = writer.Close()
+		}
+
+		bodyWriter.CloseWithError(err)
+	}()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	err := ReadUploadedFile("testfile", req, func(file FileUpload, options *UploadOptions) error {
+		if file.Size != size {
+			t.Errorf("Expected %d bytes, got %d", size, file.Size)
+		}
+
+		return nil
+	}, WithMaxFileSize(200<<20))
+
+	if err != nil {
+		t.Errorf("Expected no request size limit by default, got %v", err)
+	}
+}
+
 func TestUploadFileToDirSuccess(t *testing.T) {
 	destDir := t.TempDir()
 	fieldName := "upload"
@@ -201,3 +247,73 @@ func TestWithRandomStringSizeOption(t *testing.T) {
 		t.Errorf("Expected RandomStringSize to be %d, but got %d", size, opts.RandomStringSize)
 	}
 }
+
+func TestUploadFileToDirCollision(t *testing.T) {
+	destDir := t.TempDir()
+
+	for index, expectedErr := range []error{nil, ErrObjectExists} {
+		req := createMultipartRequest(t, "upload", "data.csv", fmt.Sprintf("upload %d", index))
+
+		if _, err := UploadFileToDir("upload", req, destDir, "{{.baseFileName}}"); !errors.Is(err, expectedErr) {
+			t.Fatalf("Expected %v, got %v", expectedErr, err)
+		}
+	}
+
+	req := createMultipartRequest(t, "upload", "data.csv", "upload 2")
+	upload, err := UploadFileToDir("upload", req, destDir, "{{.baseFileName}}", WithCollisionPolicy(CollisionRename))
+
+	if err != nil {
+		t.Fatalf("UploadFileToDir failed: %v", err)
+	}
+
+	if upload.SavedFile != filepath.Join(destDir, "data-1.csv") {
+		t.Errorf("Expected SavedFile '%s', got '%s'", filepath.Join(destDir, "data-1.csv"), upload.SavedFile)
+	}
+}
+
+func TestUploadFileToDirCreateDirs(t *testing.T) {
+	destDir := t.TempDir()
+	req := createMultipartRequest(t, "upload", "data.csv", "col1,col2")
+
+	upload, err := UploadFileToDir("upload", req, destDir, "2026/{{.baseFileName}}", WithCreateDirs(), WithFileMode(0o600))
+	if err != nil {
+		t.Fatalf("UploadFileToDir failed: %v", err)
+	}
+
+	fileInfo, err := os.Stat(upload.SavedFilePath)
+	if err != nil {
+		t.Fatalf("Failed to stat saved file: %v", err)
+	}
+
+	if fileInfo.Mode().Perm() != 0o600 {
+		t.Errorf("Expected file mode 0600, got %o", fileInfo.Mode().Perm())
+	}
+}
+
+func TestUploadFileToDirSiblingDirectory(t *testing.T) {
+	parent := t.TempDir()
+	destDir := filepath.Join(parent, "uploads")
+	os.Mkdir(destDir, 0o755)
+	os.Mkdir(filepath.Join(parent, "uploads-evil"), 0o755)
+
+	req := createMultipartRequest(t, "upload", "data.csv", "col1,col2")
+	_, err := UploadFileToDir("upload", req, destDir, "../uploads-evil/{{.baseFileName}}")
+
+	if err == nil || !strings.Contains(err.Error(), "invalid destination file path attempted") {
+		t.Errorf("Expected an invalid path error, got %v", err)
+	}
+}
+
+func TestUploadFileToDirSanitizesFileName(t *testing.T) {
+	destDir := t.TempDir()
+	req := createMultipartRequest(t, "upload", "..\\..\\invoice\u202Efdp.exe", "content")
+
+	upload, err := UploadFileToDir("upload", req, destDir, "{{.baseFileName}}")
+	if err != nil {
+		t.Fatalf("UploadFileToDir failed: %v", err)
+	}
+
+	if filepath.Base(upload.SavedFile) != "invoicefdp.exe" {
+		t.Errorf("Expected a sanitized name, got '%s'", upload.SavedFile)
+	}
+}
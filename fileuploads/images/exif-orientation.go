@@ -0,0 +1,100 @@
+package images
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const exifOrientationTag = 0x0112
+
+/*
+jpegOrientation reads the EXIF orientation of a JPEG image, from 1 to 8.
+It returns 1, meaning upright, when the image has no readable orientation.
+Only the segments before the image data are read.
+*/
+func jpegOrientation(reader io.Reader) int {
+	buffered := bufio.NewReader(reader)
+	marker := make([]byte, 2)
+
+	if _, err := io.ReadFull(buffered, marker); err != nil || marker[0] != 0xFF || marker[1] != 0xD8 {
+		return 1
+	}
+
+	for {
+		var length uint16
+
+		if _, err := io.ReadFull(buffered, marker); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+
+		// Start of scan or end of image; the metadata segments are behind us.
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return 1
+		}
+
+		if err := binary.Read(buffered, binary.BigEndian, &length); err != nil || length < 2 {
+			return 1
+		}
+
+		segment := make([]byte, length-2)
+
+		if _, err := io.ReadFull(buffered, segment); err != nil {
+			return 1
+		}
+
+		if exif, ok := bytes.CutPrefix(segment, []byte("Exif\x00\x00")); marker[1] == 0xE1 && ok {
+			return exifOrientation(exif)
+		}
+	}
+}
+
+/*
+exifOrientation finds the orientation tag in the first IFD of an EXIF
+block, which is laid out as a TIFF file.
+*/
+func exifOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int64(order.Uint32(tiff[4:8]))
+
+	if ifd+2 > int64(len(tiff)) {
+		return 1
+	}
+
+	count := int64(order.Uint16(tiff[ifd:]))
+
+	for i := range count {
+		entry := ifd + 2 + i*12
+
+		if entry+12 > int64(len(tiff)) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+
+		if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+			return orientation
+		}
+
+		return 1
+	}
+
+	return 1
+}
@@ -0,0 +1,30 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestJpegOrientation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  []byte
+		expected int
+	}{
+		{"big endian", jpegImage(t, 8, 8, 6, binary.BigEndian), 6},
+		{"little endian", jpegImage(t, 8, 8, 3, binary.LittleEndian), 3},
+		{"out of range", jpegImage(t, 8, 8, 9, binary.BigEndian), 1},
+		{"no exif", jpegImage(t, 8, 8, 0, binary.BigEndian)[:2], 1},
+		{"not a jpeg", []byte("hello world"), 1},
+		{"truncated", jpegImage(t, 8, 8, 6, binary.BigEndian)[:12], 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := jpegOrientation(bytes.NewReader(tc.content)); got != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package images
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+/*
+fitWithin scales width by height down to fit within maxWidth by
+maxHeight, keeping the aspect ratio. A zero maximum leaves that side
+unconstrained, and images are never enlarged.
+*/
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+
+	if maxWidth > 0 {
+		scale = min(scale, float64(maxWidth)/float64(width))
+	}
+
+	if maxHeight > 0 {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+
+	return max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))
+}
+
+/*
+resize scales an image to width by height by averaging the source pixels
+that fall within each destination pixel. This is a box filter, which gives
+good results when shrinking, the only direction thumbnails go.
+*/
+func resize(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := range height {
+		y0 := y * srcHeight / height
+		y1 := max((y+1)*srcHeight/height, y0+1)
+
+		for x := range width {
+			x0 := x * srcWidth / width
+			x1 := max((x+1)*srcWidth/width, x0+1)
+
+			var sum [4]int
+
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+
+				for sx := x0; sx < x1; sx++ {
+					for c := range 4 {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+
+			for c := range 4 {
+				dst.Pix[offset+c] = uint8((sum[c] + count/2) / count)
+			}
+		}
+	}
+
+	return dst
+}
+
+/*
+orient rotates and flips an image so that it displays upright, given its
+EXIF orientation. Orientations 5 to 8 swap the width and height.
+*/
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dstWidth, dstHeight := width, height
+
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := range height {
+		for x := range width {
+			var dx, dy int
+
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:])
+		}
+	}
+
+	return dst
+}
+
+/*
+toRGBA converts an image to an RGBA image whose bounds start at the
+origin, so its pixels can be addressed directly.
+*/
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	return rgba
+}
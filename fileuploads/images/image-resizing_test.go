@@ -0,0 +1,93 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFitWithin(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		width, height        int
+		maxWidth, maxHeight  int
+		expectedW, expectedH int
+	}{
+		{"landscape", 400, 200, 100, 100, 100, 50},
+		{"portrait", 200, 400, 100, 100, 50, 100},
+		{"never enlarged", 40, 20, 100, 100, 40, 20},
+		{"width only", 400, 200, 100, 0, 100, 50},
+		{"height only", 400, 200, 0, 20, 40, 20},
+		{"at least one pixel", 1000, 1, 10, 10, 10, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			width, height := fitWithin(tc.width, tc.height, tc.maxWidth, tc.maxHeight)
+
+			if width != tc.expectedW || height != tc.expectedH {
+				t.Errorf("Expected %dx%d, got %dx%d", tc.expectedW, tc.expectedH, width, height)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+
+	for y := range 2 {
+		for x := range 4 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 60), A: 255})
+		}
+	}
+
+	resized := resize(img, 2, 1)
+
+	expected := []uint8{30, 150}
+
+	for x, value := range expected {
+		if got := resized.RGBAAt(x, 0); got.R != value || got.A != 255 {
+			t.Errorf("Expected pixel %d to be %d, got %+v", x, value, got)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image whose pixels are numbered 1 to 6, row by row.
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+
+	for i := range 6 {
+		img.Pix[i] = uint8(i + 1)
+	}
+
+	testCases := []struct {
+		orientation int
+		expected    [][]uint8
+	}{
+		{1, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{2, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{3, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{4, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{5, [][]uint8{{1, 4}, {2, 5}, {3, 6}}},
+		{6, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{7, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+		{8, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+	}
+
+	for _, tc := range testCases {
+		oriented := orient(img, tc.orientation)
+
+		if oriented.Bounds().Dx() != len(tc.expected[0]) || oriented.Bounds().Dy() != len(tc.expected) {
+			t.Errorf("Orientation %d: expected %dx%d, got %v", tc.orientation, len(tc.expected[0]), len(tc.expected), oriented.Bounds())
+			continue
+		}
+
+		for y, row := range tc.expected {
+			for x, value := range row {
+				if got := color.GrayModel.Convert(oriented.At(x, y)).(color.Gray).Y; got != value {
+					t.Errorf("Orientation %d: expected %d at %d,%d, got %d", tc.orientation, value, x, y, got)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/adampresley/httphelpers/fileuploads"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("file is not a supported image")
+	ErrImageTooSmall    = errors.New("image is smaller than the minimum allowed dimensions")
+	ErrImageTooLarge    = errors.New("image exceeds the maximum allowed dimensions")
+	ErrTooManyPixels    = errors.New("image exceeds the maximum allowed pixel count")
+)
+
+/*
+supportedFormats maps the formats this package decodes to the content
+type they are saved with.
+*/
+var supportedFormats = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+}
+
+/*
+ImageUpload describes an uploaded image. The embedded FileUpload describes
+the file as it was received, while SavedFile and SavedFilePath point at the
+re-encoded copy. Width and Height are the dimensions of the saved image,
+after any EXIF rotation has been applied.
+*/
+type ImageUpload struct {
+	fileuploads.FileUpload
+
+	Format     string
+	Width      int
+	Height     int
+	Thumbnails []Thumbnail
+}
+
+/*
+Thumbnail describes a saved thumbnail of an uploaded image.
+*/
+type Thumbnail struct {
+	Name          string
+	Width         int
+	Height        int
+	SavedFile     string
+	SavedFilePath string
+}
+
+/*
+ThumbnailSize is a thumbnail to generate. Thumbnails keep the aspect ratio
+of the original and fit within Width by Height. A zero Width or Height
+leaves that side unconstrained. Images are never enlarged.
+*/
+type ThumbnailSize struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+type ImageOptions struct {
+	MinWidth      int
+	MinHeight     int
+	MaxWidth      int
+	MaxHeight     int
+	MaxPixels     int64
+	JPEGQuality   int
+	Thumbnails    []ThumbnailSize
+	UploadOptions []fileuploads.UploadOption
+}
+
+type ImageOption func(o *ImageOptions)
+
+/*
+UploadImageToDir reads an image from a request, checks its dimensions,
+re-encodes it to strip metadata, and saves it and its thumbnails to
+destDir. The file name template works as in fileuploads.UploadFileToDir.
+Thumbnails are saved alongside the image, with the thumbnail name added
+before the extension, such as "avatar-small.png".
+
+	upload, err := images.UploadImageToDir(
+		"avatar",
+		r,
+		"/path/to/avatars",
+		"{{.randomString}}{{.ext}}",
+		images.WithMaxDimensions(4000, 4000),
+		images.WithThumbnail("small", 64, 64),
+	)
+*/
+func UploadImageToDir(fieldName string, r *http.Request, destDir string, destFileNameTemplate string, options ...ImageOption) (ImageUpload, error) {
+	result, err := uploadImage(r, fieldName, destFileNameTemplate, newImageOptions(options), func(uploadOptions *fileuploads.UploadOptions) fileuploads.Storage {
+		storage := fileuploads.NewLocalStorage(destDir)
+
+		storage.Collision = uploadOptions.Collision
+		storage.FileMode = uploadOptions.FileMode
+		storage.DirMode = uploadOptions.DirMode
+		storage.CreateDirs = uploadOptions.CreateDirs
+
+		return storage
+	})
+
+	if result.SavedFile != "" {
+		result.SavedFile = filepath.Join(destDir, filepath.FromSlash(result.SavedFile))
+	}
+
+	for i := range result.Thumbnails {
+		result.Thumbnails[i].SavedFile = filepath.Join(destDir, filepath.FromSlash(result.Thumbnails[i].SavedFile))
+	}
+
+	return result, err
+}
+
+/*
+UploadImage works like UploadImageToDir, but saves the image and its
+thumbnails to storage.
+*/
+func UploadImage(storage fileuploads.Storage, fieldName string, r *http.Request, destFileNameTemplate string, options ...ImageOption) (ImageUpload, error) {
+	return uploadImage(r, fieldName, destFileNameTemplate, newImageOptions(options), func(*fileuploads.UploadOptions) fileuploads.Storage {
+		return storage
+	})
+}
+
+/*
+WithMinDimensions rejects images narrower than width or shorter than
+height with ErrImageTooSmall.
+*/
+func WithMinDimensions(width, height int) ImageOption {
+	return func(o *ImageOptions) {
+		o.MinWidth = width
+		o.MinHeight = height
+	}
+}
+
+/*
+WithMaxDimensions rejects images wider than width or taller than height
+with ErrImageTooLarge.
+*/
+func WithMaxDimensions(width, height int) ImageOption {
+	return func(o *ImageOptions) {
+		o.MaxWidth = width
+		o.MaxHeight = height
+	}
+}
+
+/*
+WithMaxPixels rejects images whose width times height exceeds pixels with
+ErrTooManyPixels. The check is made from the image header before the image
+is decoded, which guards against small files that decompress to enormous
+images. The default is 40 megapixels.
+*/
+func WithMaxPixels(pixels int64) ImageOption {
+	return func(o *ImageOptions) {
+		o.MaxPixels = pixels
+	}
+}
+
+/*
+WithJPEGQuality sets the quality, from 1 to 100, JPEG images and thumbnails
+are saved with. The default is 90.
+*/
+func WithJPEGQuality(quality int) ImageOption {
+	return func(o *ImageOptions) {
+		o.JPEGQuality = quality
+	}
+}
+
+/*
+WithThumbnail generates a thumbnail named name that fits within width by
+height.
+*/
+func WithThumbnail(name string, width, height int) ImageOption {
+	return func(o *ImageOptions) {
+		o.Thumbnails = append(o.Thumbnails, ThumbnailSize{Name: name, Width: width, Height: height})
+	}
+}
+
+/*
+WithUploadOptions passes options, such as WithMaxFileSize, through to the
+underlying file upload.
+*/
+func WithUploadOptions(options ...fileuploads.UploadOption) ImageOption {
+	return func(o *ImageOptions) {
+		o.UploadOptions = append(o.UploadOptions, options...)
+	}
+}
+
+func newImageOptions(options []ImageOption) *ImageOptions {
+	result := &ImageOptions{
+		MaxPixels:   40_000_000,
+		JPEGQuality: 90,
+	}
+
+	for _, opt := range options {
+		opt(result)
+	}
+
+	return result
+}
+
+func uploadImage(r *http.Request, fieldName string, destFileNameTemplate string, opts *ImageOptions, storageFor func(*fileuploads.UploadOptions) fileuploads.Storage) (ImageUpload, error) {
+	result := ImageUpload{}
+
+	uploadOptions := append([]fileuploads.UploadOption{fileuploads.WithAllowedTypes("image/png", "image/jpeg", "image/gif")}, opts.UploadOptions...)
+
+	err := fileuploads.ReadUploadedFile(fieldName, r, func(file fileuploads.FileUpload, options *fileuploads.UploadOptions) error {
+		result.FileUpload = file
+		return storeImage(r.Context(), storageFor(options), &result, destFileNameTemplate, opts, options)
+	}, uploadOptions...)
+
+	return result, err
+}
+
+/*
+storeImage decodes an uploaded image and saves a re-encoded copy and its
+thumbnails. If anything fails to save, whatever was already saved is
+deleted again.
+*/
+func storeImage(ctx context.Context, storage fileuploads.Storage, upload *ImageUpload, destFileNameTemplate string, opts *ImageOptions, uploadOptions *fileuploads.UploadOptions) error {
+	var (
+		err     error
+		img     image.Image
+		format  string
+		name    string
+		encoded []byte
+		info    fileuploads.ObjectInfo
+	)
+
+	if img, format, err = decodeImage(upload.File, upload.Size, opts); err != nil {
+		return fmt.Errorf("error processing image '%s': %w", upload.FileName, err)
+	}
+
+	if encoded, err = encodeImage(img, format, opts); err != nil {
+		return fmt.Errorf("error encoding image '%s': %w", upload.FileName, err)
+	}
+
+	if name, err = fileuploads.DestinationFileName(upload.FileUpload, destFileNameTemplate, uploadOptions); err != nil {
+		return err
+	}
+
+	if info, err = storage.Put(ctx, name, bytes.NewReader(encoded), supportedFormats[format]); err != nil {
+		return err
+	}
+
+	upload.Format = format
+	upload.Width = img.Bounds().Dx()
+	upload.Height = img.Bounds().Dy()
+	upload.Ext = path.Ext(info.Name)
+	upload.SavedFile = info.Name
+	upload.SavedFilePath = info.Location
+
+	for _, size := range opts.Thumbnails {
+		if err = storeThumbnail(ctx, storage, upload, img, size, opts); err != nil {
+			deleteImage(ctx, storage, upload)
+			return fmt.Errorf("error saving thumbnail '%s' of image '%s': %w", size.Name, upload.FileName, err)
+		}
+	}
+
+	return nil
+}
+
+func storeThumbnail(ctx context.Context, storage fileuploads.Storage, upload *ImageUpload, img image.Image, size ThumbnailSize, opts *ImageOptions) error {
+	var (
+		err     error
+		encoded []byte
+		info    fileuploads.ObjectInfo
+	)
+
+	width, height := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), size.Width, size.Height)
+	thumbnail := resize(img, width, height)
+
+	if encoded, err = encodeImage(thumbnail, upload.Format, opts); err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(upload.SavedFile, upload.Ext) + "-" + size.Name + upload.Ext
+
+	if info, err = storage.Put(ctx, name, bytes.NewReader(encoded), supportedFormats[upload.Format]); err != nil {
+		return err
+	}
+
+	upload.Thumbnails = append(upload.Thumbnails, Thumbnail{
+		Name:          size.Name,
+		Width:         width,
+		Height:        height,
+		SavedFile:     info.Name,
+		SavedFilePath: info.Location,
+	})
+
+	return nil
+}
+
+func deleteImage(ctx context.Context, storage fileuploads.Storage, upload *ImageUpload) {
+	storage.Delete(ctx, upload.SavedFile)
+
+	for _, thumbnail := range upload.Thumbnails {
+		storage.Delete(ctx, thumbnail.SavedFile)
+	}
+
+	upload.SavedFile = ""
+	upload.SavedFilePath = ""
+	upload.Thumbnails = nil
+}
+
+/*
+decodeImage checks an image's format and dimensions from its header, and
+only then decodes it. JPEG images are rotated upright according to their
+EXIF orientation, since the orientation is lost when the metadata is
+stripped. Only the first frame of an animated GIF is kept.
+*/
+func decodeImage(file io.ReaderAt, size int64, opts *ImageOptions) (image.Image, string, error) {
+	var (
+		err         error
+		config      image.Config
+		format      string
+		img         image.Image
+		orientation = 1
+	)
+
+	if config, format, err = image.DecodeConfig(io.NewSectionReader(file, 0, size)); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrUnsupportedImage, err)
+	}
+
+	if _, ok := supportedFormats[format]; !ok {
+		return nil, "", fmt.Errorf("%s images are not supported: %w", format, ErrUnsupportedImage)
+	}
+
+	if format == "jpeg" {
+		orientation = jpegOrientation(io.NewSectionReader(file, 0, size))
+	}
+
+	width, height := config.Width, config.Height
+
+	if orientation >= 5 {
+		width, height = height, width
+	}
+
+	if err = checkDimensions(width, height, opts); err != nil {
+		return nil, "", err
+	}
+
+	if img, _, err = image.Decode(io.NewSectionReader(file, 0, size)); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrUnsupportedImage, err)
+	}
+
+	return orient(img, orientation), format, nil
+}
+
+func checkDimensions(width, height int, opts *ImageOptions) error {
+	if opts.MaxPixels > 0 && int64(width)*int64(height) > opts.MaxPixels {
+		return fmt.Errorf("image of %dx%d pixels exceeds the limit of %d pixels: %w", width, height, opts.MaxPixels, ErrTooManyPixels)
+	}
+
+	if (opts.MaxWidth > 0 && width > opts.MaxWidth) || (opts.MaxHeight > 0 && height > opts.MaxHeight) {
+		return fmt.Errorf("image of %dx%d exceeds the limit of %dx%d: %w", width, height, opts.MaxWidth, opts.MaxHeight, ErrImageTooLarge)
+	}
+
+	if width < opts.MinWidth || height < opts.MinHeight {
+		return fmt.Errorf("image of %dx%d is smaller than %dx%d: %w", width, height, opts.MinWidth, opts.MinHeight, ErrImageTooSmall)
+	}
+
+	return nil
+}
+
+/*
+encodeImage writes an image in the given format. Only pixel data is
+written, so metadata such as EXIF, XMP and text chunks is dropped.
+*/
+func encodeImage(img image.Image, format string, opts *ImageOptions) ([]byte, error) {
+	var err error
+
+	buffer := &bytes.Buffer{}
+
+	switch format {
+	case "png":
+		err = png.Encode(buffer, img)
+
+	case "jpeg":
+		err = jpeg.Encode(buffer, img, &jpeg.Options{Quality: opts.JPEGQuality})
+
+	case "gif":
+		err = gif.Encode(buffer, img, nil)
+
+	default:
+		err = fmt.Errorf("%s images are not supported: %w", format, ErrUnsupportedImage)
+	}
+
+	return buffer.Bytes(), err
+}
@@ -0,0 +1,274 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adampresley/httphelpers/fileuploads"
+)
+
+/*
+createImageRequest is a helper function to create a new HTTP request
+containing a multipart form with an image.
+*/
+func createImageRequest(t *testing.T, fieldName, fileName string, content []byte) *http.Request {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile(fieldName, fileName)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+
+	if err := png.Encode(buffer, testImage(width, height)); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+/*
+jpegImage encodes a JPEG with an EXIF segment carrying the given
+orientation, laid out the way cameras write it.
+*/
+func jpegImage(t *testing.T, width, height int, orientation uint16, order binary.ByteOrder) []byte {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+
+	if err := jpeg.Encode(buffer, testImage(width, height), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	tiff := make([]byte, 26)
+
+	if order == binary.BigEndian {
+		copy(tiff, "MM")
+	} else {
+		copy(tiff, "II")
+	}
+
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	segment = append([]byte{0xFF, 0xE1, byte((len(segment) + 2) >> 8), byte(len(segment) + 2)}, segment...)
+
+	data := buffer.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func decodeSavedImage(t *testing.T, fileName string) (image.Image, []byte) {
+	t.Helper()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("Failed to read '%s': %v", fileName, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode '%s': %v", fileName, err)
+	}
+
+	return img, data
+}
+
+func TestUploadImageToDir(t *testing.T) {
+	destDir := t.TempDir()
+	req := createImageRequest(t, "avatar", "me.png", pngImage(t, 40, 20))
+
+	upload, err := UploadImageToDir(
+		"avatar",
+		req,
+		destDir,
+		"avatar{{.ext}}",
+		WithThumbnail("small", 10, 10),
+		WithThumbnail("large", 100, 100),
+	)
+
+	if err != nil {
+		t.Fatalf("UploadImageToDir failed: %v", err)
+	}
+
+	if upload.Format != "png" || upload.Width != 40 || upload.Height != 20 || upload.FileName != "me.png" {
+		t.Errorf("Unexpected upload %+v", upload)
+	}
+
+	if upload.SavedFile != filepath.Join(destDir, "avatar.png") {
+		t.Errorf("Expected SavedFile '%s', got '%s'", filepath.Join(destDir, "avatar.png"), upload.SavedFile)
+	}
+
+	expected := []Thumbnail{
+		{Name: "small", Width: 10, Height: 5, SavedFile: filepath.Join(destDir, "avatar-small.png"), SavedFilePath: filepath.Join(destDir, "avatar-small.png")},
+		{Name: "large", Width: 40, Height: 20, SavedFile: filepath.Join(destDir, "avatar-large.png"), SavedFilePath: filepath.Join(destDir, "avatar-large.png")},
+	}
+
+	if len(upload.Thumbnails) != len(expected) {
+		t.Fatalf("Expected %d thumbnails, got %d", len(expected), len(upload.Thumbnails))
+	}
+
+	for i, thumbnail := range upload.Thumbnails {
+		if thumbnail != expected[i] {
+			t.Errorf("Expected thumbnail %+v, got %+v", expected[i], thumbnail)
+		}
+
+		img, _ := decodeSavedImage(t, thumbnail.SavedFile)
+
+		if img.Bounds().Dx() != thumbnail.Width || img.Bounds().Dy() != thumbnail.Height {
+			t.Errorf("Expected a %dx%d thumbnail, got %v", thumbnail.Width, thumbnail.Height, img.Bounds())
+		}
+	}
+}
+
+func TestUploadImageStripsMetadata(t *testing.T) {
+	destDir := t.TempDir()
+	req := createImageRequest(t, "photo", "photo.jpg", jpegImage(t, 30, 20, 6, binary.BigEndian))
+
+	upload, err := UploadImageToDir("photo", req, destDir, "photo{{.ext}}")
+	if err != nil {
+		t.Fatalf("UploadImageToDir failed: %v", err)
+	}
+
+	img, data := decodeSavedImage(t, upload.SavedFile)
+
+	if bytes.Contains(data, []byte("Exif")) {
+		t.Errorf("Expected the EXIF segment to be stripped")
+	}
+
+	if upload.Width != 20 || upload.Height != 30 || img.Bounds().Dx() != 20 || img.Bounds().Dy() != 30 {
+		t.Errorf("Expected the image to be rotated to 20x30, got %dx%d saved as %v", upload.Width, upload.Height, img.Bounds())
+	}
+}
+
+func TestUploadImageRejected(t *testing.T) {
+	// A PNG header claiming 100000x100000 pixels, with no image data behind it.
+	bomb := pngImage(t, 1, 1)[:33]
+	binary.BigEndian.PutUint32(bomb[16:], 100000)
+	binary.BigEndian.PutUint32(bomb[20:], 100000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+
+	testCases := []struct {
+		name     string
+		fileName string
+		content  []byte
+		options  []ImageOption
+		expected error
+	}{
+		{"too small", "a.png", pngImage(t, 10, 10), []ImageOption{WithMinDimensions(16, 16)}, ErrImageTooSmall},
+		{"too wide", "a.png", pngImage(t, 50, 10), []ImageOption{WithMaxDimensions(40, 40)}, ErrImageTooLarge},
+		{"too many pixels", "a.png", pngImage(t, 50, 50), []ImageOption{WithMaxPixels(2000)}, ErrTooManyPixels},
+		{"decompression bomb", "a.png", bomb, nil, ErrTooManyPixels},
+		{"not an image", "a.txt", []byte("hello world"), nil, fileuploads.ErrFileTypeNotAllowed},
+		{"upload options", "a.png", pngImage(t, 50, 50), []ImageOption{WithUploadOptions(fileuploads.WithMaxFileSize(10))}, fileuploads.ErrFileTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destDir := t.TempDir()
+			req := createImageRequest(t, "avatar", tc.fileName, tc.content)
+
+			_, err := UploadImageToDir("avatar", req, destDir, "avatar{{.ext}}", tc.options...)
+
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+				t.Errorf("Expected nothing to be saved, got %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestUploadImageThumbnailFailure(t *testing.T) {
+	destDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(destDir, "avatar-small.png"), []byte("existing"), 0o644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	req := createImageRequest(t, "avatar", "me.png", pngImage(t, 40, 20))
+
+	upload, err := UploadImageToDir("avatar", req, destDir, "avatar{{.ext}}", WithThumbnail("small", 10, 10))
+
+	if !errors.Is(err, fileuploads.ErrObjectExists) {
+		t.Errorf("Expected ErrObjectExists, got %v", err)
+	}
+
+	if upload.SavedFile != "" {
+		t.Errorf("Expected no saved file, got '%s'", upload.SavedFile)
+	}
+
+	if _, err = os.Stat(filepath.Join(destDir, "avatar.png")); !os.IsNotExist(err) {
+		t.Errorf("Expected the image to be deleted after the thumbnail failed, got %v", err)
+	}
+}
+
+func TestUploadImage(t *testing.T) {
+	storage := fileuploads.NewMemoryStorage()
+	req := createImageRequest(t, "avatar", "me.png", pngImage(t, 40, 20))
+
+	upload, err := UploadImage(storage, "avatar", req, "avatars/{{.baseName}}{{.ext}}", WithThumbnail("small", 0, 8))
+	if err != nil {
+		t.Fatalf("UploadImage failed: %v", err)
+	}
+
+	if upload.SavedFile != "avatars/me.png" || len(upload.Thumbnails) != 1 || upload.Thumbnails[0].SavedFile != "avatars/me-small.png" {
+		t.Errorf("Unexpected upload %+v", upload)
+	}
+
+	info, err := storage.Stat(t.Context(), "avatars/me-small.png")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	if info.ContentType != "image/png" || upload.Thumbnails[0].Width != 16 || upload.Thumbnails[0].Height != 8 {
+		t.Errorf("Unexpected thumbnail %+v stored as %+v", upload.Thumbnails[0], info)
+	}
+}
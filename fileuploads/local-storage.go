@@ -0,0 +1,429 @@
+package fileuploads
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/*
+CollisionPolicy decides what LocalStorage does when a file already exists
+with the name an object is being saved under.
+*/
+type CollisionPolicy int
+
+const (
+	// CollisionFail refuses to replace the existing file with ErrObjectExists
+	CollisionFail CollisionPolicy = iota
+	// CollisionOverwrite replaces the existing file
+	CollisionOverwrite
+	// CollisionRename saves under a new name with a numeric suffix, such as "report-1.pdf"
+	CollisionRename
+)
+
+const (
+	localTempFilePrefix = ".upload-"
+	maxCollisionRenames = 1000
+)
+
+/*
+linkFile creates a hard link. Tests replace it to simulate filesystems
+without hard links.
+*/
+var linkFile = func(root *os.Root, oldName, newName string) error {
+	return root.Link(oldName, newName)
+}
+
+/*
+LocalStorage stores objects as files beneath a directory on local disk.
+
+Files are written to a temporary file in the destination directory, synced
+to disk, and only then moved into place, so a failed upload never leaves
+a truncated file behind. Collision decides what happens when the name is
+already taken. When CreateDirs is set, missing directories in an object's
+name are created with DirMode. Files are created with FileMode.
+*/
+type LocalStorage struct {
+	Dir        string
+	Collision  CollisionPolicy
+	FileMode   fs.FileMode
+	DirMode    fs.FileMode
+	CreateDirs bool
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{
+		Dir:       dir,
+		Collision: CollisionFail,
+		FileMode:  0o644,
+		DirMode:   0o755,
+	}
+}
+
+/*
+Put writes content to a file atomically. With CollisionRename the object
+may be saved under a different name, which is reported in the returned
+ObjectInfo.
+*/
+func (s *LocalStorage) Put(ctx context.Context, name string, content io.Reader, contentType string) (ObjectInfo, error) {
+	var (
+		err       error
+		root      *os.Root
+		localName string
+		tempName  string
+		tempFile  *os.File
+	)
+
+	if localName, err = s.localName(name); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if root, err = os.OpenRoot(s.Dir); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error creating file '%s': %w", name, err)
+	}
+
+	defer root.Close()
+
+	dir := filepath.Dir(localName)
+
+	if s.CreateDirs {
+		if err = root.MkdirAll(dir, orDefaultMode(s.DirMode, 0o755)); err != nil {
+			return ObjectInfo{}, fmt.Errorf("error creating directory for '%s': %w", name, err)
+		}
+	}
+
+	if tempName, tempFile, err = createTempFile(root, dir); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error creating file '%s': %w", name, err)
+	}
+
+	defer root.Remove(tempName)
+	defer tempFile.Close()
+
+	if _, err = io.Copy(tempFile, content); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error writing file '%s': %w", name, err)
+	}
+
+	if err = tempFile.Chmod(orDefaultMode(s.FileMode, 0o644)); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error setting permissions on file '%s': %w", name, err)
+	}
+
+	if err = tempFile.Sync(); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error syncing file '%s': %w", name, err)
+	}
+
+	if err = tempFile.Close(); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error closing file '%s': %w", name, err)
+	}
+
+	if name, err = s.commit(root, tempName, name); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	syncDir(root, dir)
+	return s.stat(root, name)
+}
+
+/*
+commit moves a finished temporary file into place according to the
+collision policy, returning the name it was saved under.
+*/
+func (s *LocalStorage) commit(root *os.Root, tempName, name string) (string, error) {
+	var (
+		err error
+	)
+
+	if s.Collision == CollisionOverwrite {
+		if err = root.Rename(tempName, filepath.FromSlash(name)); err != nil {
+			return "", fmt.Errorf("error moving file '%s' into place: %w", name, err)
+		}
+
+		return name, nil
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+
+	for attempt := 1; ; attempt++ {
+		if err = publishFile(root, tempName, filepath.FromSlash(candidate)); err == nil {
+			return candidate, nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("error moving file '%s' into place: %w", name, err)
+		}
+
+		if s.Collision != CollisionRename {
+			return "", fmt.Errorf("file '%s' already exists: %w", name, ErrObjectExists)
+		}
+
+		if attempt > maxCollisionRenames {
+			return "", fmt.Errorf("could not find a free name for '%s': %w", name, ErrObjectExists)
+		}
+
+		candidate = fmt.Sprintf("%s-%d%s", base, attempt, ext)
+	}
+}
+
+/*
+publishFile moves a temporary file to name without ever replacing an
+existing file, even one created by a concurrent upload. A hard link is
+used, as unlike a rename it fails if name exists. Some FUSE, SMB and
+overlay filesystems do not support hard links; there the name is claimed
+by creating it exclusively and the temporary file is renamed over it, so
+the file is empty for a moment before its content appears.
+*/
+func publishFile(root *os.Root, tempName, name string) error {
+	var (
+		err         error
+		placeholder *os.File
+	)
+
+	if err = linkFile(root, tempName, name); err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, errors.ErrUnsupported) && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+
+	if placeholder, err = root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600); err != nil {
+		return err
+	}
+
+	placeholder.Close()
+
+	if err = root.Rename(tempName, name); err != nil {
+		root.Remove(name)
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	var (
+		err       error
+		root      *os.Root
+		localName string
+		file      *os.File
+	)
+
+	if localName, err = s.localName(name); err != nil {
+		return nil, err
+	}
+
+	if root, err = os.OpenRoot(s.Dir); err != nil {
+		return nil, localStorageError(name, err)
+	}
+
+	defer root.Close()
+
+	if file, err = root.Open(localName); err != nil {
+		return nil, localStorageError(name, err)
+	}
+
+	return file, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, name string) (ObjectInfo, error) {
+	var (
+		err  error
+		root *os.Root
+	)
+
+	if _, err = s.localName(name); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if root, err = os.OpenRoot(s.Dir); err != nil {
+		return ObjectInfo{}, localStorageError(name, err)
+	}
+
+	defer root.Close()
+
+	return s.stat(root, name)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, name string) error {
+	var (
+		err       error
+		root      *os.Root
+		localName string
+	)
+
+	if localName, err = s.localName(name); err != nil {
+		return err
+	}
+
+	if root, err = os.OpenRoot(s.Dir); err != nil {
+		return fmt.Errorf("error deleting file '%s': %w", name, err)
+	}
+
+	defer root.Close()
+
+	if err = root.Remove(localName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error deleting file '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+/*
+List returns every file beneath the storage directory whose name starts
+with prefix, sorted by name.
+*/
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var (
+		err  error
+		root *os.Root
+	)
+
+	if root, err = os.OpenRoot(s.Dir); err != nil {
+		return nil, fmt.Errorf("error listing files in '%s': %w", s.Dir, err)
+	}
+
+	defer root.Close()
+
+	result := []ObjectInfo{}
+
+	err = fs.WalkDir(root.FS(), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return ctx.Err()
+		}
+
+		if strings.HasPrefix(entry.Name(), localTempFilePrefix) || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		result = append(result, s.objectInfo(name, fileInfo))
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing files in '%s': %w", s.Dir, err)
+	}
+
+	slices.SortFunc(result, func(a, b ObjectInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result, nil
+}
+
+func (s *LocalStorage) stat(root *os.Root, name string) (ObjectInfo, error) {
+	var (
+		err      error
+		fileInfo fs.FileInfo
+	)
+
+	if fileInfo, err = root.Stat(filepath.FromSlash(name)); err != nil {
+		return ObjectInfo{}, localStorageError(name, err)
+	}
+
+	if fileInfo.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("'%s' is a directory: %w", name, ErrObjectNotFound)
+	}
+
+	return s.objectInfo(path.Clean(name), fileInfo), nil
+}
+
+/*
+localName converts an object name to a path relative to the storage
+directory, refusing names that would lexically escape it. Every access
+also goes through an os.Root, which additionally refuses to follow
+symbolic links out of the directory.
+*/
+func (s *LocalStorage) localName(name string) (string, error) {
+	localName := filepath.FromSlash(name)
+
+	if !filepath.IsLocal(localName) {
+		return "", fmt.Errorf("invalid object name '%s'", name)
+	}
+
+	return localName, nil
+}
+
+func (s *LocalStorage) objectInfo(name string, fileInfo fs.FileInfo) ObjectInfo {
+	dir := s.Dir
+
+	if absolutePath, err := filepath.Abs(dir); err == nil {
+		dir = absolutePath
+	}
+
+	return ObjectInfo{
+		Name:     name,
+		Size:     fileInfo.Size(),
+		ModTime:  fileInfo.ModTime(),
+		Location: filepath.Join(dir, filepath.FromSlash(name)),
+	}
+}
+
+/*
+createTempFile creates a uniquely named file in dir within root, which
+has no equivalent of os.CreateTemp.
+*/
+func createTempFile(root *os.Root, dir string) (string, *os.File, error) {
+	for range 100 {
+		randomBytes := make([]byte, 8)
+		_, _ = rand.Read(randomBytes)
+
+		name := filepath.Join(dir, localTempFilePrefix+hex.EncodeToString(randomBytes))
+		file, err := root.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+
+		if err == nil {
+			return name, file, nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return "", nil, err
+		}
+	}
+
+	return "", nil, fmt.Errorf("could not create a unique temporary file in '%s'", dir)
+}
+
+/*
+syncDir flushes a directory entry to disk so that a file moved into it
+survives a crash. Not every platform supports this, so errors are ignored.
+*/
+func syncDir(root *os.Root, dir string) {
+	if d, err := root.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
+
+func orDefaultMode(mode, defaultMode fs.FileMode) fs.FileMode {
+	if mode == 0 {
+		return defaultMode
+	}
+
+	return mode
+}
+
+func localStorageError(name string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("file '%s' does not exist: %w", name, ErrObjectNotFound)
+	}
+
+	return fmt.Errorf("error accessing file '%s': %w", name, err)
+}
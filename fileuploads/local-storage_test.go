@@ -0,0 +1,195 @@
+package fileuploads
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "invoices", "2026"), 0o755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "photos"), 0o755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+
+	testStorageBehavior(t, NewLocalStorage(dir))
+}
+
+func TestLocalStorageLocation(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewLocalStorage(dir)
+
+	info, err := storage.Put(context.Background(), "report.txt", strings.NewReader("report"), "text/plain")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if info.Location != filepath.Join(dir, "report.txt") {
+		t.Errorf("Expected location '%s', got '%s'", filepath.Join(dir, "report.txt"), info.Location)
+	}
+}
+
+func TestLocalStorageInvalidName(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir())
+
+	for _, name := range []string{"../escape.txt", "/etc/passwd", ""} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := storage.Put(context.Background(), name, strings.NewReader("x"), ""); err == nil || !strings.Contains(err.Error(), "invalid object name") {
+				t.Errorf("Expected an invalid name error, got %v", err)
+			}
+		})
+	}
+}
+
+type failingReader struct {
+	reads int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.reads++; f.reads > 1 {
+		return 0, errors.New("connection reset")
+	}
+
+	return copy(p, "partial"), nil
+}
+
+func TestLocalStorageAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewLocalStorage(dir)
+
+	if _, err := storage.Put(context.Background(), "report.txt", &failingReader{}, ""); err == nil {
+		t.Fatal("Expected the failed copy to return an error")
+	}
+
+	entries, _ := os.ReadDir(dir)
+
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left behind, found %d", len(entries))
+	}
+}
+
+func TestLocalStorageCollisions(t *testing.T) {
+	defaultLink := linkFile
+	t.Cleanup(func() { linkFile = defaultLink })
+
+	testCases := []struct {
+		name         string
+		policy       CollisionPolicy
+		expectedName string
+		expectedErr  error
+		expected     map[string]string
+	}{
+		{"Fail", CollisionFail, "", ErrObjectExists, map[string]string{"a.txt": "first", "a-1.txt": "taken"}},
+		{"Overwrite", CollisionOverwrite, "a.txt", nil, map[string]string{"a.txt": "second", "a-1.txt": "taken"}},
+		{"Rename", CollisionRename, "a-2.txt", nil, map[string]string{"a.txt": "first", "a-1.txt": "taken", "a-2.txt": "second"}},
+	}
+
+	filesystems := []struct {
+		name string
+		link func(root *os.Root, oldName, newName string) error
+	}{
+		{"HardLinks", defaultLink},
+		{"NoHardLinks", func(root *os.Root, oldName, newName string) error {
+			return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: errors.ErrUnsupported}
+		}},
+	}
+
+	for _, filesystem := range filesystems {
+		linkFile = filesystem.link
+
+		for _, tc := range testCases {
+			t.Run(filesystem.name+"/"+tc.name, func(t *testing.T) {
+				dir := t.TempDir()
+				os.WriteFile(filepath.Join(dir, "a.txt"), []byte("first"), 0o644)
+				os.WriteFile(filepath.Join(dir, "a-1.txt"), []byte("taken"), 0o644)
+
+				storage := NewLocalStorage(dir)
+				storage.Collision = tc.policy
+
+				info, err := storage.Put(context.Background(), "a.txt", strings.NewReader("second"), "")
+
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("Expected %v, got %v", tc.expectedErr, err)
+				}
+
+				if info.Name != tc.expectedName {
+					t.Errorf("Expected name '%s', got '%s'", tc.expectedName, info.Name)
+				}
+
+				entries, _ := os.ReadDir(dir)
+
+				if len(entries) != len(tc.expected) {
+					t.Errorf("Expected %d files, got %d", len(tc.expected), len(entries))
+				}
+
+				for name, content := range tc.expected {
+					if b, _ := os.ReadFile(filepath.Join(dir, name)); string(b) != content {
+						t.Errorf("Expected %s to contain '%s', got '%s'", name, content, b)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLocalStoragePermissionsAndDirs(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewLocalStorage(dir)
+
+	if _, err := storage.Put(context.Background(), "2026/05/report.txt", strings.NewReader("x"), ""); err == nil {
+		t.Fatal("Expected an error for missing directories without CreateDirs")
+	}
+
+	storage.CreateDirs = true
+	storage.FileMode = 0o600
+	storage.DirMode = 0o700
+
+	if _, err := storage.Put(context.Background(), "2026/05/report.txt", strings.NewReader("x"), ""); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	fileInfo, err := os.Stat(filepath.Join(dir, "2026", "05", "report.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	if fileInfo.Mode().Perm() != 0o600 {
+		t.Errorf("Expected file mode 0600, got %o", fileInfo.Mode().Perm())
+	}
+
+	dirInfo, err := os.Stat(filepath.Join(dir, "2026"))
+	if err != nil {
+		t.Fatalf("Failed to stat directory: %v", err)
+	}
+
+	if dirInfo.Mode().Perm() != 0o700 {
+		t.Errorf("Expected directory mode 0700, got %o", dirInfo.Mode().Perm())
+	}
+}
+
+func TestLocalStorageSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+
+	storage := NewLocalStorage(dir)
+
+	if _, err := storage.Put(context.Background(), "link/escaped.txt", strings.NewReader("x"), ""); err == nil {
+		t.Error("Expected writing through a symlink out of the directory to fail")
+	}
+
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("Expected nothing to be written outside the directory, found %d files", len(entries))
+	}
+}
@@ -0,0 +1,105 @@
+package fileuploads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrFileInfected = errors.New("file is infected")
+	ErrScanFailed   = errors.New("file could not be scanned")
+)
+
+/*
+Scanner checks uploaded content for malware. Scan returns nil when the
+content is clean and an *InfectedError when it is not. Any other error
+means the content could not be scanned.
+*/
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) error
+}
+
+/*
+ScannerFunc adapts an ordinary function to the Scanner interface.
+*/
+type ScannerFunc func(ctx context.Context, content io.Reader) error
+
+func (f ScannerFunc) Scan(ctx context.Context, content io.Reader) error {
+	return f(ctx, content)
+}
+
+/*
+InfectedError is returned when a scanner finds malware in an uploaded
+file. Signature names what was found, such as "Eicar-Test-Signature".
+It matches ErrFileInfected with errors.Is.
+*/
+type InfectedError struct {
+	FileName  string
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	if e.FileName == "" {
+		return fmt.Sprintf("file is infected with %s", e.Signature)
+	}
+
+	return fmt.Sprintf("file '%s' is infected with %s", e.FileName, e.Signature)
+}
+
+func (e *InfectedError) Is(target error) bool {
+	return target == ErrFileInfected
+}
+
+/*
+WithScanner scans every uploaded file before it is handed to a callback
+or saved. Infected files fail with an *InfectedError. By default a file
+that cannot be scanned, for example because the scanner is unreachable,
+fails with ErrScanFailed; see WithScanFailOpen. Files read with
+StreamUploadedFiles are not scanned, since they are handed over before
+they have been read in full.
+*/
+func WithScanner(scanner Scanner) UploadOption {
+	return func(o *UploadOptions) {
+		o.Scanner = scanner
+	}
+}
+
+/*
+WithScanFailOpen accepts files that could not be scanned instead of
+rejecting them. Infected files are still rejected.
+*/
+func WithScanFailOpen() UploadOption {
+	return func(o *UploadOptions) {
+		o.ScanFailOpen = true
+	}
+}
+
+/*
+scanUploadedFile runs the configured scanner over an uploaded file. The
+file is read through ReadAt, so its read position is left untouched.
+*/
+func scanUploadedFile(ctx context.Context, file *FileUpload, opts *UploadOptions) error {
+	var infected *InfectedError
+
+	if opts.Scanner == nil {
+		return nil
+	}
+
+	err := opts.Scanner.Scan(ctx, io.NewSectionReader(file.File, 0, file.Size))
+
+	if err == nil {
+		return nil
+	}
+
+	if errors.As(err, &infected) {
+		return &InfectedError{FileName: file.FileName, Signature: infected.Signature}
+	}
+
+	if opts.ScanFailOpen {
+		return nil
+	}
+
+	return fmt.Errorf("error scanning file '%s': %w: %w", file.FileName, ErrScanFailed, err)
+}
@@ -0,0 +1,127 @@
+package fileuploads
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+const eicarMarker = "EICAR-STANDARD-ANTIVIRUS-TEST-FILE"
+
+/*
+markerScanner reports content containing the EICAR marker as infected,
+and fails outright when failWith is set.
+*/
+func markerScanner(failWith error) Scanner {
+	return ScannerFunc(func(ctx context.Context, content io.Reader) error {
+		if failWith != nil {
+			return failWith
+		}
+
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+
+		if strings.Contains(string(data), eicarMarker) {
+			return &InfectedError{Signature: "Eicar-Test-Signature"}
+		}
+
+		return nil
+	})
+}
+
+func TestReadUploadedFileScanning(t *testing.T) {
+	unreachable := errors.New("connection refused")
+
+	testCases := []struct {
+		name         string
+		content      string
+		options      []UploadOption
+		expected     error
+		callbackRuns bool
+	}{
+		{"clean", "hello world", []UploadOption{WithScanner(markerScanner(nil))}, nil, true},
+		{"infected", "X5O!P%@AP " + eicarMarker, []UploadOption{WithScanner(markerScanner(nil))}, ErrFileInfected, false},
+		{"fail closed", "hello world", []UploadOption{WithScanner(markerScanner(unreachable))}, ErrScanFailed, false},
+		{"fail open", "hello world", []UploadOption{WithScanner(markerScanner(unreachable)), WithScanFailOpen()}, nil, true},
+		{"fail open still rejects infected", eicarMarker, []UploadOption{WithScanner(markerScanner(nil)), WithScanFailOpen()}, ErrFileInfected, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createMultipartRequest(t, "upload", "notes.txt", tc.content)
+			callbackRan := false
+
+			err := ReadUploadedFile("upload", req, func(file FileUpload, options *UploadOptions) error {
+				callbackRan = true
+				content, _ := io.ReadAll(file.File)
+
+				if string(content) != tc.content {
+					t.Errorf("Expected the callback to read the whole file, got '%s'", content)
+				}
+
+				return nil
+			}, tc.options...)
+
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if callbackRan != tc.callbackRuns {
+				t.Errorf("Expected the callback to run: %v, got %v", tc.callbackRuns, callbackRan)
+			}
+		})
+	}
+}
+
+func TestInfectedError(t *testing.T) {
+	req := createMultipartRequest(t, "upload", "invoice.pdf", eicarMarker)
+	destDir := t.TempDir()
+
+	_, err := UploadFileToDir("upload", req, destDir, "{{.fileName}}", WithScanner(markerScanner(nil)))
+
+	var infected *InfectedError
+
+	if !errors.As(err, &infected) {
+		t.Fatalf("Expected an InfectedError, got %v", err)
+	}
+
+	if infected.FileName != "invoice.pdf" || infected.Signature != "Eicar-Test-Signature" {
+		t.Errorf("Unexpected error %+v", infected)
+	}
+
+	if err.Error() != "file 'invoice.pdf' is infected with Eicar-Test-Signature" {
+		t.Errorf("Unexpected message '%s'", err.Error())
+	}
+
+	if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+		t.Errorf("Expected the infected file not to be saved, got %d files", len(entries))
+	}
+}
+
+func TestReadUploadedFilesScanning(t *testing.T) {
+	req := createStreamingRequest(t,
+		testPart{fieldName: "documents", fileName: "clean.txt", content: "hello"},
+		testPart{fieldName: "documents", fileName: "infected.txt", content: eicarMarker},
+	)
+
+	files, err := ReadUploadedFiles([]string{"documents"}, req, func(file FileUpload, options *UploadOptions) error {
+		return nil
+	}, WithScanner(markerScanner(nil)))
+
+	if err != nil {
+		t.Fatalf("ReadUploadedFiles failed: %v", err)
+	}
+
+	if files[0].Err != nil {
+		t.Errorf("Expected the clean file to pass, got %v", files[0].Err)
+	}
+
+	if !errors.Is(files[1].Err, ErrFileInfected) {
+		t.Errorf("Expected the infected file to be rejected, got %v", files[1].Err)
+	}
+}
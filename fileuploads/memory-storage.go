@@ -0,0 +1,122 @@
+package fileuploads
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+MemoryStorage keeps objects in memory. It is intended for tests, where
+uploads can be inspected without touching the disk or the network.
+*/
+type MemoryStorage struct {
+	mutex   *sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	info ObjectInfo
+	data []byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		mutex:   &sync.RWMutex{},
+		objects: map[string]memoryObject{},
+	}
+}
+
+/*
+Put saves content under name, replacing any object already there.
+*/
+func (s *MemoryStorage) Put(ctx context.Context, name string, content io.Reader, contentType string) (ObjectInfo, error) {
+	var (
+		err  error
+		data []byte
+	)
+
+	if data, err = io.ReadAll(content); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error reading content for '%s': %w", name, err)
+	}
+
+	checksum := md5.Sum(data)
+
+	info := ObjectInfo{
+		Name:        name,
+		Size:        int64(len(data)),
+		ModTime:     time.Now(),
+		ContentType: contentType,
+		ETag:        "\"" + hex.EncodeToString(checksum[:]) + "\"",
+		Location:    name,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.objects[name] = memoryObject{info: info, data: data}
+	return info, nil
+}
+
+func (s *MemoryStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	object, ok := s.objects[name]
+
+	if !ok {
+		return nil, fmt.Errorf("object '%s' does not exist: %w", name, ErrObjectNotFound)
+	}
+
+	return io.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, name string) (ObjectInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	object, ok := s.objects[name]
+
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("object '%s' does not exist: %w", name, ErrObjectNotFound)
+	}
+
+	return object.info, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.objects, name)
+	return nil
+}
+
+/*
+List returns every object whose name starts with prefix, sorted by name.
+*/
+func (s *MemoryStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := []ObjectInfo{}
+
+	for name, object := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, object.info)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b ObjectInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result, nil
+}
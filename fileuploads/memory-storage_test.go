@@ -0,0 +1,9 @@
+package fileuploads
+
+import (
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	testStorageBehavior(t, NewMemoryStorage())
+}
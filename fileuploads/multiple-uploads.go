@@ -0,0 +1,121 @@
+package fileuploads
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+)
+
+/*
+ReadUploadedFiles reads every file uploaded in the given form fields and
+calls the callback for each, in field order and then in the order the files
+were sent. When no field names are provided, all file fields are read.
+
+Problems with a single file, such as exceeding MaxFileSize or an error
+returned by the callback, are recorded in that file's Err and do not stop
+the rest of the batch. The returned error is reserved for problems with
+the request as a whole: a body that cannot be parsed, no files at all,
+more than MaxFiles files, or more than MaxRequestSize bytes of files.
+*/
+func ReadUploadedFiles(fieldNames []string, r *http.Request, callback func(file FileUpload, options *UploadOptions) error, options ...UploadOption) ([]FileUpload, error) {
+	return readUploadedFiles(fieldNames, r, func(file *FileUpload, options *UploadOptions) error {
+		if err := computeChecksums(file, options); err != nil {
+			return err
+		}
+
+		return callback(*file, options)
+	}, options...)
+}
+
+/*
+UploadFilesToDir saves every file uploaded in the given form fields to
+destDir, naming each with the template the same way as UploadFileToDir.
+Files that could not be saved carry the reason in Err.
+*/
+func UploadFilesToDir(fieldNames []string, r *http.Request, destDir string, destFileNameTemplate string, options ...UploadOption) ([]FileUpload, error) {
+	result, err := UploadFiles(newLocalStorage(destDir, options), fieldNames, r, destFileNameTemplate, options...)
+
+	for index := range result {
+		if result[index].SavedFile != "" {
+			result[index].SavedFile = filepath.Join(destDir, filepath.FromSlash(result[index].SavedFile))
+		}
+	}
+
+	return result, err
+}
+
+func readUploadedFiles(fieldNames []string, r *http.Request, process func(file *FileUpload, options *UploadOptions) error, options ...UploadOption) ([]FileUpload, error) {
+	var (
+		err       error
+		totalSize int64
+	)
+
+	opts := newUploadOptions(options)
+
+	if err = parseMultipartForm(r, opts); err != nil {
+		return nil, err
+	}
+
+	if len(fieldNames) == 0 {
+		for fieldName := range r.MultipartForm.File {
+			fieldNames = append(fieldNames, fieldName)
+		}
+
+		slices.Sort(fieldNames)
+	}
+
+	result := []FileUpload{}
+
+	for _, fieldName := range fieldNames {
+		for _, info := range r.MultipartForm.File[fieldName] {
+			result = append(result, newFileUpload(fieldName, info))
+			totalSize += info.Size
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("error retrieving file info from form: %w", http.ErrMissingFile)
+	}
+
+	if opts.MaxFiles > 0 && len(result) > opts.MaxFiles {
+		return nil, fmt.Errorf("%d files exceeds the limit of %d files: %w", len(result), opts.MaxFiles, ErrTooManyFiles)
+	}
+
+	if opts.MaxRequestSize > 0 && totalSize > opts.MaxRequestSize {
+		return nil, fmt.Errorf("total file size of %d bytes exceeds the limit of %d bytes: %w", totalSize, opts.MaxRequestSize, ErrRequestTooLarge)
+	}
+
+	for index := range result {
+		result[index].Err = processUploadedFile(r.Context(), &result[index], opts, process)
+	}
+
+	return result, nil
+}
+
+func processUploadedFile(ctx context.Context, file *FileUpload, opts *UploadOptions, process func(file *FileUpload, options *UploadOptions) error) error {
+	var (
+		err          error
+		uploadedFile multipart.File
+	)
+
+	if file.Size > opts.MaxFileSize {
+		return fmt.Errorf("file size of %d bytes exceeds the limit of %d bytes: %w", file.Size, opts.MaxFileSize, ErrFileTooLarge)
+	}
+
+	if uploadedFile, err = file.info.Open(); err != nil {
+		return fmt.Errorf("error opening uploaded file '%s': %w", file.FileName, err)
+	}
+
+	defer uploadedFile.Close()
+
+	file.File = uploadedFile
+
+	if err = inspectUploadedFile(ctx, file, opts); err != nil {
+		return err
+	}
+
+	return process(file, opts)
+}
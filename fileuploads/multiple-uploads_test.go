@@ -0,0 +1,162 @@
+package fileuploads
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func createMultipleFilesRequest(t *testing.T) *http.Request {
+	t.Helper()
+
+	return createStreamingRequest(t,
+		testPart{fieldName: "title", content: "Contract"},
+		testPart{fieldName: "documents", fileName: "contract.pdf", content: "contract"},
+		testPart{fieldName: "documents", fileName: "appendix.pdf", content: "appendix"},
+		testPart{fieldName: "images", fileName: "signature.png", content: "a much larger signature image"},
+	)
+}
+
+func TestReadUploadedFiles(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fieldNames []string
+		expected   []string
+	}{
+		{"OneField", []string{"documents"}, []string{"documents/contract.pdf", "documents/appendix.pdf"}},
+		{"ManyFields", []string{"images", "documents"}, []string{"images/signature.png", "documents/contract.pdf", "documents/appendix.pdf"}},
+		{"AllFields", nil, []string{"documents/contract.pdf", "documents/appendix.pdf", "images/signature.png"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createMultipleFilesRequest(t)
+			contents := map[string]string{}
+
+			files, err := ReadUploadedFiles(tc.fieldNames, req, func(file FileUpload, options *UploadOptions) error {
+				b, err := io.ReadAll(file.File)
+				contents[file.FileName] = string(b)
+				return err
+			})
+
+			if err != nil {
+				t.Fatalf("ReadUploadedFiles failed: %v", err)
+			}
+
+			got := []string{}
+
+			for _, file := range files {
+				if file.Err != nil {
+					t.Errorf("Unexpected error for %s: %v", file.FileName, file.Err)
+				}
+
+				if file.Ext != ".pdf" && file.Ext != ".png" {
+					t.Errorf("Unexpected extension '%s'", file.Ext)
+				}
+
+				if file.Size != int64(len(contents[file.FileName])) {
+					t.Errorf("Expected size %d for %s, got %d", len(contents[file.FileName]), file.FileName, file.Size)
+				}
+
+				got = append(got, file.FieldName+"/"+file.FileName)
+			}
+
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Expected files %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadUploadedFilesPerFileErrors(t *testing.T) {
+	req := createMultipleFilesRequest(t)
+	callbackErr := errors.New("appendix rejected")
+
+	files, err := ReadUploadedFiles(nil, req, func(file FileUpload, options *UploadOptions) error {
+		if file.FileName == "appendix.pdf" {
+			return callbackErr
+		}
+
+		return nil
+	}, WithMaxFileSize(10))
+
+	if err != nil {
+		t.Fatalf("ReadUploadedFiles failed: %v", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %d", len(files))
+	}
+
+	if files[0].Err != nil {
+		t.Errorf("Expected contract.pdf to succeed, got %v", files[0].Err)
+	}
+
+	if !errors.Is(files[1].Err, callbackErr) {
+		t.Errorf("Expected the callback error for appendix.pdf, got %v", files[1].Err)
+	}
+
+	if !errors.Is(files[2].Err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge for signature.png, got %v", files[2].Err)
+	}
+}
+
+func TestReadUploadedFilesRequestErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fieldNames []string
+		options    []UploadOption
+		expected   error
+	}{
+		{"TooManyFiles", nil, []UploadOption{WithMaxFiles(2)}, ErrTooManyFiles},
+		{"RequestTooLarge", nil, []UploadOption{WithMaxRequestSize(100)}, ErrRequestTooLarge},
+		{"NoFiles", []string{"missing"}, nil, http.ErrMissingFile},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createMultipleFilesRequest(t)
+
+			files, err := ReadUploadedFiles(tc.fieldNames, req, func(file FileUpload, options *UploadOptions) error {
+				t.Fatal("Callback was called when it should not have been")
+				return nil
+			}, tc.options...)
+
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if files != nil {
+				t.Errorf("Expected no files, got %v", files)
+			}
+		})
+	}
+}
+
+func TestUploadFilesToDir(t *testing.T) {
+	destDir := t.TempDir()
+	req := createMultipleFilesRequest(t)
+
+	files, err := UploadFilesToDir([]string{"documents"}, req, destDir, "{{.baseFileName}}")
+	if err != nil {
+		t.Fatalf("UploadFilesToDir failed: %v", err)
+	}
+
+	for _, file := range files {
+		if file.Err != nil {
+			t.Fatalf("Unexpected error for %s: %v", file.FileName, file.Err)
+		}
+
+		savedContent, err := os.ReadFile(file.SavedFilePath)
+		if err != nil {
+			t.Fatalf("Failed to read saved file: %v", err)
+		}
+
+		if string(savedContent) != strings.TrimSuffix(file.FileName, ".pdf") {
+			t.Errorf("Unexpected content '%s' for %s", string(savedContent), file.FileName)
+		}
+	}
+}
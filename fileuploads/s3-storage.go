@@ -0,0 +1,498 @@
+package fileuploads
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+/*
+S3Config configures an S3Storage. Endpoint is the base URL of the service,
+such as "https://s3.us-east-1.amazonaws.com" or "http://localhost:9000"
+for a local stand-in. Objects are addressed path style
+(endpoint/bucket/key) unless VirtualHostedStyle is set, in which case the
+bucket becomes part of the host name. Client defaults to
+http.DefaultClient.
+*/
+type S3Config struct {
+	Endpoint           string
+	Region             string
+	Bucket             string
+	AccessKeyID        string
+	SecretAccessKey    string
+	SessionToken       string
+	VirtualHostedStyle bool
+	Client             S3HttpClient
+}
+
+/*
+S3HttpClient sends the requests made by S3Storage, and is satisfied by
+*http.Client. It matches httphelpers.HttpClient, so the clients from that
+package can be used, but is declared here so that fileuploads does not
+import the root package and the test helpers that come with it.
+*/
+type S3HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+/*
+S3Storage stores objects in an S3 compatible bucket. Requests are signed
+with AWS Signature Version 4. Payloads are sent unsigned so that uploads
+can be streamed without reading them twice.
+*/
+type S3Storage struct {
+	config S3Config
+	now    func() time.Time
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3ErrorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func NewS3Storage(config S3Config) *S3Storage {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	return &S3Storage{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+/*
+Put uploads content to the bucket, replacing any object already there.
+S3 needs to know the size of an upload up front, so content that cannot
+seek is buffered in memory first.
+*/
+func (s *S3Storage) Put(ctx context.Context, name string, content io.Reader, contentType string) (ObjectInfo, error) {
+	var (
+		err      error
+		size     int64
+		req      *http.Request
+		response *http.Response
+	)
+
+	if content, size, err = s3ContentSize(content); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error reading content for '%s': %w", name, err)
+	}
+
+	headers := http.Header{}
+
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+
+	if req, err = s.newRequest(ctx, http.MethodPut, name, nil, io.NopCloser(content), headers); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	req.ContentLength = size
+
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	if response, err = s.do(req, "upload", name); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	response.Body.Close()
+
+	return ObjectInfo{
+		Name:        name,
+		Size:        size,
+		ModTime:     s.now(),
+		ContentType: contentType,
+		ETag:        response.Header.Get("ETag"),
+		Location:    req.URL.String(),
+	}, nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	var (
+		err      error
+		req      *http.Request
+		response *http.Response
+	)
+
+	if req, err = s.newRequest(ctx, http.MethodGet, name, nil, nil, nil); err != nil {
+		return nil, err
+	}
+
+	if response, err = s.do(req, "download", name); err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, name string) (ObjectInfo, error) {
+	var (
+		err      error
+		req      *http.Request
+		response *http.Response
+	)
+
+	if req, err = s.newRequest(ctx, http.MethodHead, name, nil, nil, nil); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if response, err = s.do(req, "stat", name); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	response.Body.Close()
+
+	modTime, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+
+	return ObjectInfo{
+		Name:        name,
+		Size:        response.ContentLength,
+		ModTime:     modTime,
+		ContentType: response.Header.Get("Content-Type"),
+		ETag:        response.Header.Get("ETag"),
+		Location:    req.URL.String(),
+	}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, name string) error {
+	var (
+		err      error
+		req      *http.Request
+		response *http.Response
+	)
+
+	if req, err = s.newRequest(ctx, http.MethodDelete, name, nil, nil, nil); err != nil {
+		return err
+	}
+
+	// Some S3 compatible services answer 404 for a missing object, which
+	// is not an error for Delete.
+	if response, err = s.do(req, "delete", name); errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	response.Body.Close()
+	return nil
+}
+
+/*
+List returns every object whose name starts with prefix, following
+continuation tokens until the listing is complete.
+*/
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var (
+		err      error
+		req      *http.Request
+		response *http.Response
+	)
+
+	result := []ObjectInfo{}
+	continuationToken := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		if req, err = s.newRequest(ctx, http.MethodGet, "", query, nil, nil); err != nil {
+			return nil, err
+		}
+
+		if response, err = s.do(req, "list", prefix); err != nil {
+			return nil, err
+		}
+
+		listing := s3ListBucketResult{}
+		err = xml.NewDecoder(response.Body).Decode(&listing)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("error decoding s3 listing for '%s': %w", prefix, err)
+		}
+
+		for _, object := range listing.Contents {
+			result = append(result, ObjectInfo{
+				Name:     object.Key,
+				Size:     object.Size,
+				ModTime:  object.LastModified,
+				ETag:     object.ETag,
+				Location: s.objectURL(object.Key, nil).String(),
+			})
+		}
+
+		if !listing.IsTruncated || listing.NextContinuationToken == "" {
+			return result, nil
+		}
+
+		continuationToken = listing.NextContinuationToken
+	}
+}
+
+/*
+newRequest creates a signed request for an object, or for the bucket when
+key is empty. Requests with a body are signed with an unsigned payload.
+*/
+func (s *S3Storage) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader, headers http.Header) (*http.Request, error) {
+	var (
+		err error
+		req *http.Request
+	)
+
+	if req, err = http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), body); err != nil {
+		return nil, fmt.Errorf("error creating s3 request: %w", err)
+	}
+
+	for header, values := range headers {
+		req.Header[header] = values
+	}
+
+	payloadHash := s3EmptyPayload
+
+	if body != nil {
+		payloadHash = s3UnsignedPayload
+	}
+
+	s.sign(req, payloadHash)
+	return req, nil
+}
+
+func (s *S3Storage) do(req *http.Request, operation, name string) (*http.Response, error) {
+	var (
+		err      error
+		response *http.Response
+	)
+
+	if response, err = s.config.Client.Do(req); err != nil {
+		return nil, fmt.Errorf("error during s3 %s of '%s': %w", operation, name, err)
+	}
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+
+	defer response.Body.Close()
+
+	errorResponse := s3ErrorResponse{}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	_ = xml.Unmarshal(body, &errorResponse)
+
+	if response.StatusCode == http.StatusNotFound && errorResponse.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("s3 object '%s' does not exist: %w", name, ErrObjectNotFound)
+	}
+
+	return nil, fmt.Errorf("error during s3 %s of '%s': status %d %s %s", operation, name, response.StatusCode, errorResponse.Code, errorResponse.Message)
+}
+
+/*
+objectURL builds the URL of an object, or of the bucket when key is empty.
+The path is escaped the way SigV4 expects, so the same string is used for
+the request and its signature.
+*/
+func (s *S3Storage) objectURL(key string, query url.Values) *url.URL {
+	result, err := url.Parse(strings.TrimSuffix(s.config.Endpoint, "/"))
+
+	if err != nil {
+		result = &url.URL{Scheme: "https", Host: s.config.Endpoint}
+	}
+
+	objectPath := "/" + key
+
+	if s.config.VirtualHostedStyle {
+		result.Host = s.config.Bucket + "." + result.Host
+	} else if key == "" {
+		objectPath = "/" + s.config.Bucket
+	} else {
+		objectPath = "/" + s.config.Bucket + "/" + key
+	}
+
+	result.Path = result.Path + objectPath
+	result.RawPath = s3Escape(result.Path, false)
+	result.RawQuery = s3CanonicalQuery(query)
+
+	return result
+}
+
+/*
+sign adds AWS Signature Version 4 headers to a request. Host, Content-Type,
+Range, and every X-Amz header are signed.
+*/
+func (s *S3Storage) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	host := req.Host
+
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+
+		if strings.HasPrefix(key, "x-amz-") || key == "content-type" || key == "content-md5" || key == "range" {
+			headers[key] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	headerNames := make([]string, 0, len(headers))
+
+	for key := range headers {
+		headerNames = append(headerNames, key)
+	}
+
+	slices.Sort(headerNames)
+
+	canonicalHeaders := strings.Builder{}
+
+	for _, key := range headerNames {
+		canonicalHeaders.WriteString(key + ":" + headers[key] + "\n")
+	}
+
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), now.Format("20060102"))
+	signingKey = hmacSHA256(signingKey, s.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+/*
+s3Escape percent-encodes everything except unreserved characters, and
+slashes unless encodeSlash is set, as SigV4 requires.
+*/
+func s3Escape(value string, encodeSlash bool) string {
+	result := strings.Builder{}
+
+	for _, b := range []byte(value) {
+		switch {
+		case (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9'),
+			b == '-', b == '_', b == '.', b == '~',
+			b == '/' && !encodeSlash:
+			result.WriteByte(b)
+
+		default:
+			fmt.Fprintf(&result, "%%%02X", b)
+		}
+	}
+
+	return result.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	pairs := []string{}
+
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+/*
+s3ContentSize determines how many bytes content holds, buffering it when
+the size cannot be found by seeking.
+*/
+func s3ContentSize(content io.Reader) (io.Reader, int64, error) {
+	if seeker, ok := content.(io.Seeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return content, 0, err
+		}
+
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return content, 0, err
+		}
+
+		if _, err = seeker.Seek(current, io.SeekStart); err != nil {
+			return content, 0, err
+		}
+
+		return content, end - current, nil
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if _, err := io.Copy(buffer, content); err != nil {
+		return content, 0, err
+	}
+
+	return buffer, int64(buffer.Len()), nil
+}
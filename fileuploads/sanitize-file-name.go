@@ -0,0 +1,88 @@
+package fileuploads
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxFileNameLength = 255
+	maxExtLength      = 32
+)
+
+var reservedDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+/*
+SanitizeFileName turns a client supplied file name into one that is safe
+to use on any common file system. It keeps only the last path component,
+whether separated by slashes or backslashes, and removes control
+characters, invalid UTF-8, and invisible formatting characters such as
+the right-to-left override that makes an executable named
+"invoice<RLO>fdp.exe" display as "invoiceexe.pdf".
+Characters reserved on Windows become underscores, leading and trailing
+dots and spaces are trimmed, and reserved device names such as CON or
+LPT1 are prefixed with an underscore. The result is at most 255 bytes,
+keeping the extension where possible. An empty result becomes "unnamed".
+*/
+func SanitizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+
+		return r
+	}, name)
+
+	name = strings.Trim(name, ". ")
+
+	if name == "" {
+		return "unnamed"
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+
+	if reservedDeviceNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = "_" + name
+	}
+
+	return truncateFileName(name)
+}
+
+/*
+truncateFileName shortens a name to maxFileNameLength bytes on a rune
+boundary, keeping a reasonably short extension intact.
+*/
+func truncateFileName(name string) string {
+	if len(name) <= maxFileNameLength {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+
+	if len(ext) > maxExtLength {
+		ext = ""
+	}
+
+	stem := strings.TrimSuffix(name, ext)
+	limit := maxFileNameLength - len(ext)
+
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+
+	return strings.TrimRight(stem[:limit], ". ") + ext
+}
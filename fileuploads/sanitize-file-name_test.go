@@ -0,0 +1,52 @@
+package fileuploads
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain", "report.pdf", "report.pdf"},
+		{"UnixPath", "../../etc/passwd", "passwd"},
+		{"WindowsPath", `C:\Users\adam\report.pdf`, "report.pdf"},
+		{"ControlCharacters", "bad\x00na\r\nme.txt", "badname.txt"},
+		{"InvalidUTF8", "bad\xffname.txt", "badname.txt"},
+		{"BidiOverride", "invoice\u202Efdp.exe", "invoicefdp.exe"},
+		{"ZeroWidth", "re\u200Bport\uFEFF.pdf", "report.pdf"},
+		{"ReservedCharacters", `what<>:"|?*.txt`, "what_______.txt"},
+		{"LeadingDots", "...htaccess", "htaccess"},
+		{"TrailingDotsAndSpaces", "report.pdf. . ", "report.pdf"},
+		{"DeviceName", "CON", "_CON"},
+		{"DeviceNameWithExtension", "lpt1.txt", "_lpt1.txt"},
+		{"DeviceNameLookalike", "CONSOLE.txt", "CONSOLE.txt"},
+		{"Unicode", "résumé 報告.pdf", "résumé 報告.pdf"},
+		{"Empty", "", "unnamed"},
+		{"OnlyDots", "..", "unnamed"},
+		{"TrailingSlash", "uploads/", "unnamed"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SanitizeFileName(tc.input); got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileNameLength(t *testing.T) {
+	got := SanitizeFileName(strings.Repeat("é", 200) + ".pdf")
+
+	if len(got) > maxFileNameLength {
+		t.Errorf("Expected at most %d bytes, got %d", maxFileNameLength, len(got))
+	}
+
+	if !strings.HasSuffix(got, "é.pdf") {
+		t.Errorf("Expected the extension to be kept on a rune boundary, got '%s'", got)
+	}
+}
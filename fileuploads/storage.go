@@ -0,0 +1,127 @@
+package fileuploads
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"time"
+)
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	ErrObjectExists   = errors.New("object already exists")
+)
+
+/*
+ObjectInfo describes an object held in a Storage. Name is the slash
+separated name the object was stored under. Location is where the object
+can be found outside of the Storage, such as an absolute path on disk or
+a URL.
+*/
+type ObjectInfo struct {
+	Name        string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+	ETag        string
+	Location    string
+}
+
+/*
+Storage is a place uploaded files can be saved to. Object names are slash
+separated paths, such as "invoices/2026/inv-100.pdf", regardless of how
+the backend lays them out. Open and Stat return an error wrapping
+ErrObjectNotFound when no object has the given name. Deleting an object
+that does not exist is not an error.
+
+What Put does when the name is already taken is up to each backend.
+LocalStorage follows its Collision policy, while MemoryStorage and
+S3Storage replace the existing object. WithCollisionPolicy only reaches
+the LocalStorage built by UploadFileToDir and UploadFilesToDir.
+*/
+type Storage interface {
+	Put(ctx context.Context, name string, content io.Reader, contentType string) (ObjectInfo, error)
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	Stat(ctx context.Context, name string) (ObjectInfo, error)
+	Delete(ctx context.Context, name string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+/*
+UploadFile reads a file from a request and saves it to storage, using a
+template to generate the object name. SavedFile is set to the object name
+and SavedFilePath to its location in the storage. Whether an existing
+object is replaced depends on the storage; see Storage.
+*/
+func UploadFile(storage Storage, fieldName string, r *http.Request, destFileNameTemplate string, options ...UploadOption) (FileUpload, error) {
+	result := &FileUpload{}
+
+	err := readUploadedFile(fieldName, r, func(file *FileUpload, options *UploadOptions) error {
+		result = file
+		return storeUploadedFile(r.Context(), storage, result, destFileNameTemplate, options)
+	}, options...)
+
+	return *result, err
+}
+
+/*
+UploadFiles saves every file uploaded in the given form fields to storage,
+the same way as UploadFilesToDir.
+*/
+func UploadFiles(storage Storage, fieldNames []string, r *http.Request, destFileNameTemplate string, options ...UploadOption) ([]FileUpload, error) {
+	return readUploadedFiles(fieldNames, r, func(file *FileUpload, options *UploadOptions) error {
+		return storeUploadedFile(r.Context(), storage, file, destFileNameTemplate, options)
+	}, options...)
+}
+
+/*
+storeUploadedFile saves an uploaded file to storage, using the template
+to name it, and records where it was saved. Checksums are computed as the
+file is saved, unless the template needs them to name it. A file that
+does not match the client's digest is deleted again.
+*/
+func storeUploadedFile(ctx context.Context, storage Storage, file *FileUpload, destFileNameTemplate string, options *UploadOptions) error {
+	var (
+		err  error
+		name string
+		info ObjectInfo
+		sums *checksummer
+	)
+
+	content := io.Reader(file.File)
+
+	if templateUsesChecksums(destFileNameTemplate) {
+		err = computeChecksums(file, options)
+	} else if sums, err = newChecksummer(file.FileName, file.Header, options); err == nil {
+		content = io.TeeReader(file.File, sums)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if name, err = DestinationFileName(*file, destFileNameTemplate, options); err != nil {
+		return err
+	}
+
+	if info, err = storage.Put(ctx, name, content, file.DetectedContentType); err != nil {
+		return err
+	}
+
+	if sums != nil {
+		file.Checksums = sums.checksums()
+
+		if err = sums.verify(); err != nil {
+			storage.Delete(ctx, info.Name)
+			return err
+		}
+	}
+
+	file.Ext = path.Ext(info.Name)
+	file.SavedFile = info.Name
+	file.SavedFilePath = info.Location
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package fileuploads
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+/*
+testStorageBehavior exercises the behavior every Storage implementation
+must share.
+*/
+func testStorageBehavior(t *testing.T, storage Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	for _, name := range []string{"invoices/2026/b.pdf", "invoices/2026/a.pdf", "photos/c.png"} {
+		info, err := storage.Put(ctx, name, strings.NewReader("content of "+name), "application/pdf")
+		if err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		if info.Name != name || info.Size != int64(len("content of "+name)) || info.Location == "" {
+			t.Errorf("Unexpected object info %+v", info)
+		}
+	}
+
+	reader, err := storage.Open(ctx, "invoices/2026/a.pdf")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+
+	if string(content) != "content of invoices/2026/a.pdf" {
+		t.Errorf("Unexpected content '%s'", content)
+	}
+
+	objects, err := storage.List(ctx, "invoices/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	if len(objects) != 2 || objects[0].Name != "invoices/2026/a.pdf" || objects[1].Name != "invoices/2026/b.pdf" {
+		t.Errorf("Unexpected listing %+v", objects)
+	}
+
+	if err = storage.Delete(ctx, "photos/c.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if err = storage.Delete(ctx, "photos/c.png"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %v", err)
+	}
+
+	if _, err = storage.Stat(ctx, "photos/c.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Expected ErrObjectNotFound from Stat, got %v", err)
+	}
+
+	if _, err = storage.Open(ctx, "photos/c.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Expected ErrObjectNotFound from Open, got %v", err)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	storage := NewMemoryStorage()
+	req := createMultipartRequest(t, "upload", "photo.png", pngContent)
+
+	upload, err := UploadFile(storage, "upload", req, "images/{{.baseFileName}}")
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	if upload.SavedFile != "images/photo.png" {
+		t.Errorf("Expected SavedFile 'images/photo.png', got '%s'", upload.SavedFile)
+	}
+
+	info, err := storage.Stat(context.Background(), "images/photo.png")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	if info.ContentType != "image/png" {
+		t.Errorf("Expected the detected content type to be stored, got '%s'", info.ContentType)
+	}
+}
+
+func TestUploadFileInvalidPath(t *testing.T) {
+	testCases := []string{"../{{.baseFileName}}", "/etc/{{.baseFileName}}", "a/../../{{.baseFileName}}"}
+
+	for _, template := range testCases {
+		t.Run(template, func(t *testing.T) {
+			req := createMultipartRequest(t, "upload", "data.csv", "a,b")
+
+			_, err := UploadFile(NewMemoryStorage(), "upload", req, template)
+
+			if err == nil || !strings.Contains(err.Error(), "invalid destination file path attempted") {
+				t.Errorf("Expected an invalid path error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadFiles(t *testing.T) {
+	storage := NewMemoryStorage()
+	req := createMultipleFilesRequest(t)
+
+	files, err := UploadFiles(storage, []string{"documents"}, req, "docs/{{.baseFileName}}")
+	if err != nil {
+		t.Fatalf("UploadFiles failed: %v", err)
+	}
+
+	objects, _ := storage.List(context.Background(), "docs/")
+
+	if len(files) != 2 || len(objects) != 2 {
+		t.Errorf("Expected 2 stored files, got %d files and %d objects", len(files), len(objects))
+	}
+}
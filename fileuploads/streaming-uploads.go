@@ -0,0 +1,272 @@
+package fileuploads
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+)
+
+/*
+maxUnreadFileSize is how much of a file StreamUploadedFiles discards when
+a callback does not read it to the end.
+*/
+const maxUnreadFileSize = 64 << 10
+
+/*
+defaultMaxStreamRequestSize is the MaxRequestSize StreamUploadedFiles uses
+unless WithMaxRequestSize is given. Unlike ParseMultipartForm, nothing
+else bounds a streamed request.
+*/
+const defaultMaxStreamRequestSize = 100 << 20
+
+/*
+UploadPart is a single file handed to the StreamUploadedFiles callback as
+it arrives. Reader yields the file's content directly from the request
+body and fails with ErrFileTooLarge once MaxFileSize is exceeded, or with
+ErrDigestMismatch at the end when the file does not match the client's
+digest. DetectedContentType is sniffed from the beginning of the content.
+Form holds the regular form fields that arrived before this file.
+*/
+type UploadPart struct {
+	FieldName           string
+	FileName            string
+	Ext                 string
+	DetectedContentType string
+	Header              textproto.MIMEHeader
+	Reader              io.Reader
+	Form                url.Values
+
+	sums *checksummer
+}
+
+/*
+Checksums returns the checksums of what has been read from Reader. They
+describe the whole file once Reader has been read to the end.
+*/
+func (p UploadPart) Checksums() Checksums {
+	if p.sums == nil {
+		return Checksums{}
+	}
+
+	return p.sums.checksums()
+}
+
+/*
+StreamUploadedFiles reads a multipart request one part at a time, without
+buffering files in memory or temporary files. Each file is handed to the
+callback as it arrives, and must be consumed before the callback returns.
+Regular form fields are collected and returned, and the fields received so
+far are available to each part.
+
+Size limits are enforced while reading. A file larger than MaxFileSize, a
+field larger than MaxFieldSize, or a body larger than MaxRequestSize stops
+processing immediately with ErrFileTooLarge, ErrFieldTooLarge, or
+ErrRequestTooLarge. MaxRequestSize defaults to 100MB here. More than
+MaxFormFields fields fails with ErrTooManyFields, and more than MaxFormSize
+bytes of fields in total with ErrFieldTooLarge. An error returned by the
+callback also stops processing and is returned as is.
+
+A callback that does not read its file to the end may leave up to 64KB
+unread, which is discarded. Anything more fails with ErrFileNotConsumed,
+rather than reading the rest of a file nobody wants.
+*/
+func StreamUploadedFiles(r *http.Request, callback func(part UploadPart, options *UploadOptions) error, options ...UploadOption) (url.Values, error) {
+	var (
+		err    error
+		reader *multipart.Reader
+		part   *multipart.Part
+	)
+
+	opts := newUploadOptions(append([]UploadOption{WithMaxRequestSize(defaultMaxStreamRequestSize)}, options...))
+	form := url.Values{}
+	fields, formSize := 0, int64(0)
+
+	if opts.MaxRequestSize > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, opts.MaxRequestSize)
+	}
+
+	if reader, err = r.MultipartReader(); err != nil {
+		return form, fmt.Errorf("error reading multipart data: %w", err)
+	}
+
+	for {
+		if part, err = reader.NextPart(); err == io.EOF {
+			return form, nil
+		}
+
+		if err != nil {
+			return form, requestReadError(err)
+		}
+
+		// Parts are only closed once they have been read, as closing a part
+		// reads whatever is left of it. On error the rest of the body is
+		// left unread.
+		if part.FileName() == "" {
+			if fields++; opts.MaxFormFields > 0 && fields > opts.MaxFormFields {
+				return form, fmt.Errorf("request has more than %d form fields: %w", opts.MaxFormFields, ErrTooManyFields)
+			}
+
+			if err = readFormField(part, form, &formSize, opts); err != nil {
+				return form, err
+			}
+
+			part.Close()
+			continue
+		}
+
+		limited := &limitedReader{
+			reader: part,
+			limit:  opts.MaxFileSize,
+			err:    fmt.Errorf("file '%s' exceeds the limit of %d bytes: %w", part.FileName(), opts.MaxFileSize, ErrFileTooLarge),
+		}
+
+		uploadPart := UploadPart{
+			FieldName: part.FormName(),
+			FileName:  part.FileName(),
+			Ext:       filepath.Ext(part.FileName()),
+			Header:    part.Header,
+			Form:      cloneValues(form),
+		}
+
+		if uploadPart.sums, err = newChecksummer(uploadPart.FileName, uploadPart.Header, opts); err != nil {
+			return form, err
+		}
+
+		uploadPart.DetectedContentType, uploadPart.Reader, err = detectStreamType(limited, uploadPart.Ext)
+		uploadPart.Reader = &checksumReader{reader: uploadPart.Reader, sums: uploadPart.sums}
+
+		// A file that exceeds MaxFileSize while its type is detected still
+		// reaches the callback, whose reader fails at the limit as usual.
+		if limited.exceeded {
+			err = nil
+		}
+
+		if err == nil {
+			err = checkFileType(uploadPart.FileName, uploadPart.Ext, uploadPart.DetectedContentType, opts)
+		}
+
+		if err == nil {
+			err = callback(uploadPart, opts)
+		}
+
+		if err == nil && limited.exceeded {
+			err = limited.err
+		}
+
+		if err == nil {
+			err = discardUnreadFile(uploadPart.Reader, uploadPart.FileName)
+		}
+
+		if err != nil {
+			return form, err
+		}
+
+		part.Close()
+	}
+}
+
+/*
+discardUnreadFile reads what a callback left of a file, failing once more
+than maxUnreadFileSize bytes remain.
+*/
+func discardUnreadFile(reader io.Reader, fileName string) error {
+	n, err := io.CopyN(io.Discard, reader, maxUnreadFileSize+1)
+
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if n > maxUnreadFileSize {
+		return fmt.Errorf("file '%s' was not read by the callback: %w", fileName, ErrFileNotConsumed)
+	}
+
+	return nil
+}
+
+func readFormField(part *multipart.Part, form url.Values, formSize *int64, opts *UploadOptions) error {
+	var (
+		err   error
+		value []byte
+	)
+
+	limited := &limitedReader{
+		reader: part,
+		limit:  opts.MaxFieldSize,
+		err:    fmt.Errorf("form field '%s' exceeds the limit of %d bytes: %w", part.FormName(), opts.MaxFieldSize, ErrFieldTooLarge),
+	}
+
+	if value, err = io.ReadAll(limited); err != nil {
+		return err
+	}
+
+	if *formSize += int64(len(value)); opts.MaxFormSize > 0 && *formSize > opts.MaxFormSize {
+		return fmt.Errorf("form fields exceed the total limit of %d bytes: %w", opts.MaxFormSize, ErrFieldTooLarge)
+	}
+
+	form.Add(part.FormName(), string(value))
+	return nil
+}
+
+func requestReadError(err error) error {
+	var (
+		maxBytesErr *http.MaxBytesError
+	)
+
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Errorf("request exceeds the limit of %d bytes: %w", maxBytesErr.Limit, ErrRequestTooLarge)
+	}
+
+	return fmt.Errorf("error reading multipart data: %w", err)
+}
+
+func cloneValues(values url.Values) url.Values {
+	result := make(url.Values, len(values))
+
+	for key, value := range values {
+		result[key] = append([]string(nil), value...)
+	}
+
+	return result
+}
+
+/*
+limitedReader reads at most limit bytes, returning err once the
+underlying reader proves to have more. Errors from the request body are
+translated so that ErrRequestTooLarge reaches the caller.
+*/
+type limitedReader struct {
+	reader   io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+	err      error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, l.err
+	}
+
+	if remaining := l.limit - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+
+	if l.read > l.limit {
+		l.exceeded = true
+		return n - int(l.read-l.limit), l.err
+	}
+
+	if err != nil && err != io.EOF {
+		err = requestReadError(err)
+	}
+
+	return n, err
+}
@@ -0,0 +1,289 @@
+package fileuploads
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testPart struct {
+	fieldName string
+	fileName  string
+	content   string
+}
+
+/*
+createStreamingRequest is a helper function to create a new HTTP request
+containing a multipart form with the given fields and files, in order.
+*/
+func createStreamingRequest(t *testing.T, parts ...testPart) *http.Request {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	for _, part := range parts {
+		var (
+			err error
+			w   io.Writer
+		)
+
+		if part.fileName == "" {
+			w, err = writer.CreateFormField(part.fieldName)
+		} else {
+			w, err = writer.CreateFormFile(part.fieldName, part.fileName)
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to create form part: %v", err)
+		}
+
+		if _, err = io.WriteString(w, part.content); err != nil {
+			t.Fatalf("Failed to write part content: %v", err)
+		}
+	}
+
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+func TestStreamUploadedFilesSuccess(t *testing.T) {
+	req := createStreamingRequest(t,
+		testPart{fieldName: "title", content: "Holiday"},
+		testPart{fieldName: "photos", fileName: "beach.jpg", content: "beach data"},
+		testPart{fieldName: "photos", fileName: "sunset.png", content: "sunset data"},
+		testPart{fieldName: "tags", content: "summer"},
+	)
+
+	got := map[string]string{}
+
+	form, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+		content, err := io.ReadAll(part.Reader)
+		if err != nil {
+			return err
+		}
+
+		if part.FieldName != "photos" {
+			t.Errorf("Expected field name 'photos', got '%s'", part.FieldName)
+		}
+
+		if part.Form.Get("title") != "Holiday" {
+			t.Errorf("Expected the title field to be available, got '%s'", part.Form.Get("title"))
+		}
+
+		if part.Ext != ".jpg" && part.Ext != ".png" {
+			t.Errorf("Unexpected extension '%s'", part.Ext)
+		}
+
+		got[part.FileName] = string(content)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("StreamUploadedFiles failed: %v", err)
+	}
+
+	if got["beach.jpg"] != "beach data" || got["sunset.png"] != "sunset data" {
+		t.Errorf("Unexpected files %v", got)
+	}
+
+	if form.Get("title") != "Holiday" || form.Get("tags") != "summer" {
+		t.Errorf("Expected all form fields, got %v", form)
+	}
+}
+
+func TestStreamUploadedFilesLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		parts    []testPart
+		options  []UploadOption
+		expected error
+	}{
+		{
+			name:     "FileTooLarge",
+			parts:    []testPart{{fieldName: "file", fileName: "large.txt", content: "this content is larger than 10 bytes"}},
+			options:  []UploadOption{WithMaxFileSize(10)},
+			expected: ErrFileTooLarge,
+		},
+		{
+			name:     "FieldTooLarge",
+			parts:    []testPart{{fieldName: "comment", content: strings.Repeat("a", 20)}},
+			options:  []UploadOption{WithMaxFieldSize(10)},
+			expected: ErrFieldTooLarge,
+		},
+		{
+			name:     "RequestTooLarge",
+			parts:    []testPart{{fieldName: "file", fileName: "data.bin", content: strings.Repeat("a", 1000)}},
+			options:  []UploadOption{WithMaxRequestSize(500)},
+			expected: ErrRequestTooLarge,
+		},
+		{
+			name:     "TooManyFields",
+			parts:    []testPart{{fieldName: "a", content: "1"}, {fieldName: "b", content: "2"}, {fieldName: "c", content: "3"}},
+			options:  []UploadOption{WithMaxFormFields(2)},
+			expected: ErrTooManyFields,
+		},
+		{
+			name:     "FormTooLarge",
+			parts:    []testPart{{fieldName: "a", content: strings.Repeat("a", 8)}, {fieldName: "b", content: strings.Repeat("b", 8)}},
+			options:  []UploadOption{WithMaxFieldSize(10), WithMaxFormSize(12)},
+			expected: ErrFieldTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createStreamingRequest(t, tc.parts...)
+
+			_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+				_, err := io.Copy(io.Discard, part.Reader)
+				return err
+			}, tc.options...)
+
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestStreamUploadedFilesAbortsOnOversize(t *testing.T) {
+	req := createStreamingRequest(t,
+		testPart{fieldName: "file", fileName: "large.txt", content: strings.Repeat("a", 100)},
+		testPart{fieldName: "file", fileName: "next.txt", content: "never read"},
+	)
+
+	calls := 0
+
+	_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+		calls++
+
+		// Ignore the read error to make sure the limit is still enforced
+		content, _ := io.ReadAll(part.Reader)
+
+		if len(content) != 10 {
+			t.Errorf("Expected reading to stop at 10 bytes, got %d", len(content))
+		}
+
+		return nil
+	}, WithMaxFileSize(10))
+
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected processing to stop after the first file, got %d calls", calls)
+	}
+}
+
+func TestStreamUploadedFilesOversizeWhileSniffing(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxFileSize int64
+		content     string
+		contentType string
+	}{
+		{"InsideSniffWindow", 100, "%PDF-1.7\n" + strings.Repeat("a", 200), "application/pdf"},
+		{"AfterSniffWindow", sniffLength + 10, "%PDF-1.7\n" + strings.Repeat("a", sniffLength+100), "application/pdf"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createStreamingRequest(t, testPart{fieldName: "file", fileName: "large.pdf", content: tc.content})
+			calls := 0
+
+			_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+				calls++
+
+				if part.DetectedContentType != tc.contentType {
+					t.Errorf("Expected content type '%s', got '%s'", tc.contentType, part.DetectedContentType)
+				}
+
+				content, err := io.ReadAll(part.Reader)
+
+				if int64(len(content)) != tc.maxFileSize || !errors.Is(err, ErrFileTooLarge) {
+					t.Errorf("Expected %d bytes and ErrFileTooLarge, got %d and %v", tc.maxFileSize, len(content), err)
+				}
+
+				return nil
+			}, WithMaxFileSize(tc.maxFileSize), WithAllowedTypes("application/pdf"))
+
+			if !errors.Is(err, ErrFileTooLarge) {
+				t.Errorf("Expected ErrFileTooLarge, got %v", err)
+			}
+
+			if calls != 1 {
+				t.Errorf("Expected the callback to be called once, got %d calls", calls)
+			}
+		})
+	}
+}
+
+func TestStreamUploadedFilesUnreadFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		size     int
+		expected error
+	}{
+		{"SmallRemainderIsDiscarded", maxUnreadFileSize, nil},
+		{"LargeRemainderFails", sniffLength + maxUnreadFileSize + 1, ErrFileNotConsumed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := createStreamingRequest(t,
+				testPart{fieldName: "file", fileName: "skipped.txt", content: strings.Repeat("a", tc.size)},
+				testPart{fieldName: "title", content: "after"},
+			)
+
+			form, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+				return nil
+			}, WithMaxFileSize(1<<20))
+
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if tc.expected == nil && form.Get("title") != "after" {
+				t.Errorf("Expected the fields after the file to be read, got %v", form)
+			}
+		})
+	}
+}
+
+func TestStreamUploadedFilesCallbackError(t *testing.T) {
+	req := createStreamingRequest(t, testPart{fieldName: "file", fileName: "a.txt", content: "a"})
+	callbackErr := errors.New("storage unavailable")
+
+	_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+		return callbackErr
+	})
+
+	if !errors.Is(err, callbackErr) {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+}
+
+func TestStreamUploadedFilesNotMultipart(t *testing.T) {
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err := StreamUploadedFiles(req, func(part UploadPart, options *UploadOptions) error {
+		t.Fatal("Callback was called for a non multipart request")
+		return nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "error reading multipart data") {
+		t.Errorf("Expected a multipart error, got %v", err)
+	}
+}
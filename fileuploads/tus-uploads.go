@@ -0,0 +1,863 @@
+package fileuploads
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tusVersion            = "1.0.0"
+	tusExtensions         = "creation,creation-with-upload,termination,expiration,checksum"
+	tusChecksumAlgorithms = "sha1,md5,sha256"
+	tusContentType        = "application/offset+octet-stream"
+	tusInfoSuffix         = ".info"
+	tusPartSuffix         = ".part"
+	tusDoneSuffix         = ".done"
+)
+
+/*
+StatusChecksumMismatch is the status the tus checksum extension returns
+when a chunk does not match its Upload-Checksum header.
+*/
+const StatusChecksumMismatch = 460
+
+/*
+TusHandler serves resumable uploads using version 1.0.0 of the tus
+protocol (https://tus.io), with the creation, creation-with-upload,
+termination, expiration and checksum extensions. Chunks are saved to a
+Storage as they arrive, so an interrupted upload can be resumed from the
+last byte received. Once every byte has arrived the chunks are assembled
+and passed to a completion callback as a FileUpload, the same way
+ReadUploadedFile does, after which they are deleted. A completed upload
+is remembered until it expires, so a client that lost the final response
+can confirm it with HEAD instead of uploading again.
+
+Errors are answered with a generic message. The details, which may name
+files or storage locations, are logged to ErrorLog, and are not logged at
+all when ErrorLog is nil.
+
+Uploads are locked while a request is working on them, but only within a
+single TusHandler. Run one handler per storage, or route every request for
+an upload to the same instance.
+*/
+type TusHandler struct {
+	ErrorLog *log.Logger
+
+	storage    Storage
+	basePath   string
+	onComplete func(file FileUpload, options *UploadOptions) error
+	opts       *UploadOptions
+	now        func() time.Time
+
+	mutex *sync.Mutex
+	locks map[string]*tusLock
+}
+
+type tusLock struct {
+	mutex *sync.Mutex
+	count int
+}
+
+/*
+tusInfo is saved alongside the chunks of an upload when it is created.
+It is never rewritten; the current offset is worked out from the chunks.
+*/
+type tusInfo struct {
+	Length      int64             `json:"length"`
+	Metadata    map[string]string `json:"metadata"`
+	RawMetadata string            `json:"rawMetadata"`
+	Expires     time.Time         `json:"expires"`
+}
+
+/*
+NewTusHandler creates a tus handler mounted at basePath, such as
+"/files/". Uploads are created by posting to basePath and addressed as
+basePath followed by their ID. onComplete is called with the assembled
+file once an upload finishes. The file name and content type are taken
+from the "filename" and "filetype" metadata the client sends. Uploads
+larger than MaxFileSize are refused, uploads are forgotten after the
+expiration (24 hours by default), and the type and checksum options are
+applied to the assembled file.
+
+	handler := fileuploads.NewTusHandler(
+		fileuploads.NewLocalStorage("/var/uploads/partial"),
+		"/files/",
+		func(file fileuploads.FileUpload, options *fileuploads.UploadOptions) error {
+			// process file.File
+			return nil
+		},
+		fileuploads.WithMaxFileSize(1<<30),
+		fileuploads.WithExpiration(2*time.Hour),
+	)
+
+	mux.Handle("/files/", handler)
+*/
+func NewTusHandler(storage Storage, basePath string, onComplete func(file FileUpload, options *UploadOptions) error, options ...UploadOption) *TusHandler {
+	return &TusHandler{
+		storage:    storage,
+		basePath:   "/" + strings.Trim(basePath, "/") + "/",
+		onComplete: onComplete,
+		opts:       newUploadOptions(options),
+		now:        time.Now,
+		mutex:      &sync.Mutex{},
+		locks:      map[string]*tusLock{},
+	}
+}
+
+/*
+WithExpiration sets how long a resumable upload is kept after it is
+created. Expired uploads are refused and their chunks deleted.
+*/
+func WithExpiration(expiration time.Duration) UploadOption {
+	return func(o *UploadOptions) {
+		o.Expiration = expiration
+	}
+}
+
+func (h *TusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.Method
+
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" {
+		method = strings.ToUpper(override)
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.opts.MaxFileSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(h.basePath, "/")), "/")
+
+	if id == "" {
+		if method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		h.create(w, r)
+		return
+	}
+
+	if !isTusID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	unlock := h.lock(id)
+	defer unlock()
+
+	switch method {
+	case http.MethodHead:
+		h.head(w, r, id)
+
+	case http.MethodPatch:
+		h.patch(w, r, id)
+
+	case http.MethodDelete:
+		h.terminate(w, r, id)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+/*
+CleanupExpired deletes the chunks of every upload that has expired.
+Expired uploads are also removed when a client next touches them, so
+this only needs to run periodically to reclaim space from clients that
+never come back.
+*/
+func (h *TusHandler) CleanupExpired(ctx context.Context) error {
+	var (
+		err     error
+		objects []ObjectInfo
+	)
+
+	if objects, err = h.storage.List(ctx, ""); err != nil {
+		return fmt.Errorf("error listing uploads: %w", err)
+	}
+
+	for _, object := range objects {
+		id, ok := strings.CutSuffix(object.Name, tusInfoSuffix)
+
+		if !ok || !isTusID(id) {
+			continue
+		}
+
+		if err = h.cleanupIfExpired(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *TusHandler) cleanupIfExpired(ctx context.Context, id string) error {
+	unlock := h.lock(id)
+	defer unlock()
+
+	info, err := h.readInfo(ctx, id)
+
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if h.expired(info) {
+		return h.deleteUpload(ctx, id)
+	}
+
+	return nil
+}
+
+func (h *TusHandler) create(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		length   int64
+		metadata map[string]string
+	)
+
+	if length, err = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64); err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length header", http.StatusBadRequest)
+		return
+	}
+
+	if length > h.opts.MaxFileSize {
+		http.Error(w, fmt.Sprintf("upload of %d bytes exceeds the limit of %d bytes", length, h.opts.MaxFileSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if metadata, err = parseTusMetadata(r.Header.Get("Upload-Metadata")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := newTusID()
+
+	info := tusInfo{
+		Length:      length,
+		Metadata:    metadata,
+		RawMetadata: r.Header.Get("Upload-Metadata"),
+		Expires:     h.expiration(),
+	}
+
+	encoded, _ := json.Marshal(info)
+
+	if _, err = h.storage.Put(r.Context(), id+tusInfoSuffix, bytes.NewReader(encoded), "application/json"); err != nil {
+		http.Error(w, "error creating upload", http.StatusInternalServerError)
+		return
+	}
+
+	unlock := h.lock(id)
+	defer unlock()
+
+	w.Header().Set("Location", path.Join(h.basePath, id))
+	w.Header().Set("Upload-Expires", info.Expires.UTC().Format(http.TimeFormat))
+
+	if r.Header.Get("Content-Type") != tusContentType {
+		if length == 0 {
+			h.complete(w, r, id, info, http.StatusCreated)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	h.appendChunk(w, r, id, info, 0, http.StatusCreated)
+}
+
+func (h *TusHandler) head(w http.ResponseWriter, r *http.Request, id string) {
+	info, offset, _, ok := h.loadUpload(w, r, id)
+
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	w.Header().Set("Upload-Expires", info.Expires.UTC().Format(http.TimeFormat))
+
+	if info.RawMetadata != "" {
+		w.Header().Set("Upload-Metadata", info.RawMetadata)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TusHandler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != tusContentType {
+		http.Error(w, "Content-Type must be "+tusContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	info, offset, completed, ok := h.loadUpload(w, r, id)
+
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("Upload-Offset") != strconv.FormatInt(offset, 10) {
+		http.Error(w, fmt.Sprintf("upload is at offset %d", offset), http.StatusConflict)
+		return
+	}
+
+	// A client retrying the final request of an upload that has already
+	// completed is told so, without running the callback again.
+	if completed {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		w.Header().Set("Upload-Expires", info.Expires.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.appendChunk(w, r, id, info, offset, http.StatusNoContent)
+}
+
+func (h *TusHandler) terminate(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := h.readInfo(r.Context(), id); err != nil {
+		h.writeLoadError(w, r, err)
+		return
+	}
+
+	if err := h.deleteUpload(r.Context(), id); err != nil {
+		http.Error(w, "error terminating upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+appendChunk saves the request body as the chunk starting at offset. When
+the client sent no checksum, a body cut short by a dropped connection is
+kept, so the client can resume from where it got to.
+*/
+func (h *TusHandler) appendChunk(w http.ResponseWriter, r *http.Request, id string, info tusInfo, offset int64, status int) {
+	var (
+		err      error
+		expected []byte
+		checksum hash.Hash
+		stored   ObjectInfo
+	)
+
+	remaining := info.Length - offset
+
+	if r.ContentLength > remaining {
+		http.Error(w, fmt.Sprintf("chunk exceeds the %d bytes remaining", remaining), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if checksum, expected, err = parseTusChecksum(r.Header.Get("Upload-Checksum")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader = io.LimitReader(r.Body, remaining)
+
+	if checksum == nil {
+		body = &tolerantReader{reader: body}
+	} else {
+		body = io.TeeReader(body, checksum)
+	}
+
+	name := tusPartName(id, offset)
+
+	if stored, err = h.storage.Put(r.Context(), name, body, tusContentType); err != nil {
+		http.Error(w, "error saving chunk", http.StatusInternalServerError)
+		return
+	}
+
+	if checksum != nil && !bytes.Equal(checksum.Sum(nil), expected) {
+		h.storage.Delete(r.Context(), name)
+		http.Error(w, "chunk does not match its checksum", StatusChecksumMismatch)
+		return
+	}
+
+	if stored.Size == 0 {
+		h.storage.Delete(r.Context(), name)
+	}
+
+	offset += stored.Size
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Expires", info.Expires.UTC().Format(http.TimeFormat))
+
+	if offset == info.Length {
+		h.complete(w, r, id, info, status)
+		return
+	}
+
+	w.WriteHeader(status)
+}
+
+/*
+complete assembles the chunks of a finished upload into a temporary file
+and hands it to the completion callback. On success the chunks are
+deleted, but the upload is remembered as complete until it expires. On
+failure the whole upload is deleted, since the client has nothing left to
+resume.
+*/
+func (h *TusHandler) complete(w http.ResponseWriter, r *http.Request, id string, info tusInfo, status int) {
+	var (
+		err  error
+		file *os.File
+		sums *checksummer
+	)
+
+	ctx := context.WithoutCancel(r.Context())
+	fileName := info.Metadata["filename"]
+
+	if fileName == "" {
+		fileName = info.Metadata["name"]
+	}
+
+	header := textproto.MIMEHeader{}
+
+	if contentType := info.Metadata["filetype"]; contentType != "" {
+		header.Set("Content-Type", contentType)
+	} else if contentType = info.Metadata["type"]; contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	upload := newFileUpload("", &multipart.FileHeader{Filename: fileName, Header: header, Size: info.Length})
+
+	if sums, err = newChecksummer(fileName, header, h.opts); err == nil {
+		file, err = h.assemble(r.Context(), id, sums)
+	}
+
+	if err != nil {
+		h.deleteUpload(ctx, id)
+		h.writeError(w, fmt.Errorf("error assembling upload '%s': %w", id, err))
+		return
+	}
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	upload.File = file
+	upload.Checksums = sums.checksums()
+
+	if err = inspectUploadedFile(r.Context(), &upload, h.opts); err == nil {
+		err = h.onComplete(upload, h.opts)
+	}
+
+	if err != nil {
+		h.deleteUpload(ctx, id)
+		h.writeError(w, fmt.Errorf("error completing upload '%s': %w", id, err))
+		return
+	}
+
+	// The completion record is saved before the chunks are deleted, so an
+	// interruption never makes a completed upload look unfinished.
+	if _, err = h.storage.Put(ctx, id+tusDoneSuffix, http.NoBody, "application/octet-stream"); err == nil {
+		err = h.deleteChunks(ctx, id)
+	}
+
+	if err != nil {
+		h.logf("error recording completed upload '%s': %v", id, err)
+	}
+
+	w.WriteHeader(status)
+}
+
+/*
+writeError answers with the status for err and a generic message, and
+logs err itself, as it may describe storage paths or other internals.
+*/
+func (h *TusHandler) writeError(w http.ResponseWriter, err error) {
+	status := tusErrorStatus(err)
+
+	h.logf("tus: %v", err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (h *TusHandler) logf(format string, args ...any) {
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf(format, args...)
+	}
+}
+
+/*
+assemble copies the chunks of an upload into a temporary file, feeding
+them to sums on the way.
+*/
+func (h *TusHandler) assemble(ctx context.Context, id string, sums *checksummer) (*os.File, error) {
+	var (
+		err   error
+		file  *os.File
+		parts []ObjectInfo
+	)
+
+	if parts, err = h.parts(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if file, err = os.CreateTemp("", "tus-upload-*"); err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+
+	for _, part := range parts {
+		if err = copyObject(ctx, h.storage, part.Name, io.MultiWriter(file, sums)); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("error rewinding assembled upload: %w", err)
+	}
+
+	return file, nil
+}
+
+/*
+loadUpload reads an upload, the offset it has reached, and whether it has
+completed, writing an error response and returning false when it cannot
+be used.
+*/
+func (h *TusHandler) loadUpload(w http.ResponseWriter, r *http.Request, id string) (tusInfo, int64, bool, bool) {
+	var (
+		err    error
+		info   tusInfo
+		parts  []ObjectInfo
+		offset int64
+	)
+
+	if info, err = h.readInfo(r.Context(), id); err != nil {
+		h.writeLoadError(w, r, err)
+		return info, 0, false, false
+	}
+
+	if h.expired(info) {
+		h.deleteUpload(r.Context(), id)
+		http.Error(w, "upload has expired", http.StatusGone)
+		return info, 0, false, false
+	}
+
+	if _, err = h.storage.Stat(r.Context(), id+tusDoneSuffix); err == nil {
+		return info, info.Length, true, true
+	}
+
+	if !errors.Is(err, ErrObjectNotFound) {
+		h.writeLoadError(w, r, err)
+		return info, 0, false, false
+	}
+
+	if parts, err = h.parts(r.Context(), id); err != nil {
+		h.writeLoadError(w, r, err)
+		return info, 0, false, false
+	}
+
+	for _, part := range parts {
+		offset += part.Size
+	}
+
+	return info, offset, false, true
+}
+
+func (h *TusHandler) writeLoadError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrObjectNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.writeError(w, err)
+}
+
+func (h *TusHandler) readInfo(ctx context.Context, id string) (tusInfo, error) {
+	var (
+		err    error
+		info   tusInfo
+		reader io.ReadCloser
+	)
+
+	if reader, err = h.storage.Open(ctx, id+tusInfoSuffix); err != nil {
+		return info, fmt.Errorf("error opening upload '%s': %w", id, err)
+	}
+
+	defer reader.Close()
+
+	if err = json.NewDecoder(reader).Decode(&info); err != nil {
+		return info, fmt.Errorf("error decoding upload '%s': %w", id, err)
+	}
+
+	return info, nil
+}
+
+/*
+parts finds the chunks of an upload in order. Each chunk is named after
+the offset it starts at, so they are looked up one after the other
+rather than by listing the storage, which can be slow.
+*/
+func (h *TusHandler) parts(ctx context.Context, id string) ([]ObjectInfo, error) {
+	var (
+		offset int64
+	)
+
+	result := []ObjectInfo{}
+
+	for {
+		object, err := h.storage.Stat(ctx, tusPartName(id, offset))
+
+		if errors.Is(err, ErrObjectNotFound) || (err == nil && object.Size == 0) {
+			return result, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading chunks of upload '%s': %w", id, err)
+		}
+
+		offset += object.Size
+		result = append(result, object)
+	}
+}
+
+/*
+deleteUpload deletes every object of an upload. The info is deleted last,
+so an interrupted delete can be finished by CleanupExpired.
+*/
+func (h *TusHandler) deleteUpload(ctx context.Context, id string) error {
+	if err := h.deleteChunks(ctx, id); err != nil {
+		return err
+	}
+
+	for _, name := range []string{id + tusDoneSuffix, id + tusInfoSuffix} {
+		if err := h.storage.Delete(ctx, name); err != nil {
+			return fmt.Errorf("error deleting '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+deleteChunks deletes the chunks of an upload, last first, so that an
+interrupted delete leaves chunks that can still be found.
+*/
+func (h *TusHandler) deleteChunks(ctx context.Context, id string) error {
+	parts, err := h.parts(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for index := len(parts) - 1; index >= 0; index-- {
+		if err = h.storage.Delete(ctx, parts[index].Name); err != nil {
+			return fmt.Errorf("error deleting '%s': %w", parts[index].Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *TusHandler) expiration() time.Time {
+	expiration := h.opts.Expiration
+
+	if expiration <= 0 {
+		expiration = 24 * time.Hour
+	}
+
+	return h.now().Add(expiration)
+}
+
+func (h *TusHandler) expired(info tusInfo) bool {
+	return !h.now().Before(info.Expires)
+}
+
+/*
+lock serializes requests for an upload. Locks are dropped once no request
+is waiting on them.
+*/
+func (h *TusHandler) lock(id string) func() {
+	h.mutex.Lock()
+
+	entry, ok := h.locks[id]
+
+	if !ok {
+		entry = &tusLock{mutex: &sync.Mutex{}}
+		h.locks[id] = entry
+	}
+
+	entry.count++
+	h.mutex.Unlock()
+
+	entry.mutex.Lock()
+
+	return func() {
+		entry.mutex.Unlock()
+
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		if entry.count--; entry.count == 0 {
+			delete(h.locks, id)
+		}
+	}
+}
+
+/*
+tolerantReader ends a read at the first error rather than returning it,
+so whatever arrived before a connection dropped can still be saved.
+*/
+type tolerantReader struct {
+	reader io.Reader
+}
+
+func (t *tolerantReader) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+
+	if err != nil {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func copyObject(ctx context.Context, storage Storage, name string, dest io.Writer) error {
+	reader, err := storage.Open(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error opening '%s': %w", name, err)
+	}
+
+	defer reader.Close()
+
+	if _, err = io.Copy(dest, reader); err != nil {
+		return fmt.Errorf("error copying '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+/*
+parseTusMetadata decodes an Upload-Metadata header, a comma separated list
+of keys each followed by an optional base64 encoded value.
+*/
+func parseTusMetadata(value string) (map[string]string, error) {
+	result := map[string]string{}
+
+	if strings.TrimSpace(value) == "" {
+		return result, nil
+	}
+
+	for pair := range strings.SplitSeq(value, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+
+		if key == "" {
+			return nil, fmt.Errorf("malformed Upload-Metadata '%s'", value)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("malformed Upload-Metadata value for '%s': %w", key, err)
+		}
+
+		result[key] = string(decoded)
+	}
+
+	return result, nil
+}
+
+/*
+parseTusChecksum reads an Upload-Checksum header, such as
+"sha1 Kq5sNclPz7QV2+lfQIuc6R7oRu0=". It returns a nil hash when no header
+was sent.
+*/
+func parseTusChecksum(value string) (hash.Hash, []byte, error) {
+	if value == "" {
+		return nil, nil, nil
+	}
+
+	algorithm, encoded, _ := strings.Cut(value, " ")
+
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed Upload-Checksum: %w", err)
+	}
+
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), expected, nil
+
+	case "md5":
+		return md5.New(), expected, nil
+
+	case "sha256":
+		return sha256.New(), expected, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
+}
+
+func tusErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+
+	case errors.Is(err, ErrFileTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+
+	case errors.Is(err, ErrFileInfected):
+		return http.StatusUnprocessableEntity
+
+	case errors.Is(err, ErrScanFailed):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+func tusPartName(id string, offset int64) string {
+	return fmt.Sprintf("%s.%020d%s", id, offset, tusPartSuffix)
+}
+
+func newTusID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+func isTusID(id string) bool {
+	decoded, err := hex.DecodeString(id)
+	return err == nil && len(decoded) == 16 && hex.EncodeToString(decoded) == id
+}
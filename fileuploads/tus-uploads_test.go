@@ -0,0 +1,506 @@
+package fileuploads
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tusTestServer struct {
+	handler   *TusHandler
+	completed []FileUpload
+	contents  []string
+}
+
+func newTusTestServer(t *testing.T, storage Storage, options ...UploadOption) *tusTestServer {
+	t.Helper()
+
+	server := &tusTestServer{}
+
+	server.handler = NewTusHandler(storage, "/files/", func(file FileUpload, options *UploadOptions) error {
+		content, err := io.ReadAll(file.File)
+		if err != nil {
+			return err
+		}
+
+		server.completed = append(server.completed, file)
+		server.contents = append(server.contents, string(content))
+		return nil
+	}, options...)
+
+	server.handler.ErrorLog = log.New(io.Discard, "", 0)
+	return server
+}
+
+func (s *tusTestServer) do(method, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", "1.0.0")
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func (s *tusTestServer) create(t *testing.T, length string, metadata string) string {
+	t.Helper()
+
+	response := s.do(http.MethodPost, "/files/", map[string]string{"Upload-Length": length, "Upload-Metadata": metadata}, "")
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", response.Code, response.Body.String())
+	}
+
+	return response.Header().Get("Location")
+}
+
+func (s *tusTestServer) patch(location string, offset string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	all := map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}
+
+	for key, value := range headers {
+		all[key] = value
+	}
+
+	return s.do(http.MethodPatch, location, all, body)
+}
+
+func tusMetadata(pairs ...string) string {
+	result := []string{}
+
+	for i := 0; i < len(pairs); i += 2 {
+		result = append(result, pairs[i]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[i+1])))
+	}
+
+	return strings.Join(result, ",")
+}
+
+func TestTusHandlerUpload(t *testing.T) {
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"local":  NewLocalStorage(t.TempDir()),
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			server := newTusTestServer(t, storage)
+			location := server.create(t, "11", tusMetadata("filename", "hello.txt", "filetype", "text/plain"))
+
+			if !strings.HasPrefix(location, "/files/") {
+				t.Fatalf("Expected a location under /files/, got '%s'", location)
+			}
+
+			response := server.patch(location, "0", "hello", nil)
+
+			if response.Code != http.StatusNoContent || response.Header().Get("Upload-Offset") != "5" {
+				t.Fatalf("Expected status 204 at offset 5, got %d at '%s'", response.Code, response.Header().Get("Upload-Offset"))
+			}
+
+			response = server.do(http.MethodHead, location, nil, "")
+
+			if response.Header().Get("Upload-Offset") != "5" || response.Header().Get("Upload-Length") != "11" {
+				t.Errorf("Expected offset 5 of 11, got %s of %s", response.Header().Get("Upload-Offset"), response.Header().Get("Upload-Length"))
+			}
+
+			if response.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Expected Cache-Control no-store, got '%s'", response.Header().Get("Cache-Control"))
+			}
+
+			if len(server.completed) != 0 {
+				t.Fatalf("Expected no completed uploads yet, got %d", len(server.completed))
+			}
+
+			response = server.patch(location, "5", " world", nil)
+
+			if response.Code != http.StatusNoContent || response.Header().Get("Upload-Offset") != "11" {
+				t.Fatalf("Expected status 204 at offset 11, got %d: %s", response.Code, response.Body.String())
+			}
+
+			if len(server.completed) != 1 {
+				t.Fatalf("Expected 1 completed upload, got %d", len(server.completed))
+			}
+
+			file := server.completed[0]
+
+			if server.contents[0] != "hello world" {
+				t.Errorf("Expected content 'hello world', got '%s'", server.contents[0])
+			}
+
+			if file.FileName != "hello.txt" || file.Ext != ".txt" || file.Size != 11 {
+				t.Errorf("Unexpected file %+v", file)
+			}
+
+			if file.Header.Get("Content-Type") != "text/plain" || file.DetectedContentType != "text/plain" {
+				t.Errorf("Expected text/plain, got '%s' and '%s'", file.Header.Get("Content-Type"), file.DetectedContentType)
+			}
+
+			if file.Checksums.SHA256 != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+				t.Errorf("Unexpected SHA256 '%s'", file.Checksums.SHA256)
+			}
+
+			objects, _ := storage.List(context.Background(), "")
+
+			for _, object := range objects {
+				if strings.HasSuffix(object.Name, ".part") {
+					t.Errorf("Expected the chunks to be deleted, got %+v", objects)
+				}
+			}
+
+			response = server.do(http.MethodHead, location, nil, "")
+
+			if response.Code != http.StatusOK || response.Header().Get("Upload-Offset") != "11" || response.Header().Get("Upload-Length") != "11" {
+				t.Errorf("Expected status 200 at offset 11 of 11 after completion, got %d at %s of %s", response.Code, response.Header().Get("Upload-Offset"), response.Header().Get("Upload-Length"))
+			}
+
+			if response = server.patch(location, "11", "", nil); response.Code != http.StatusNoContent || response.Header().Get("Upload-Offset") != "11" {
+				t.Errorf("Expected a retried final request to get status 204 at offset 11, got %d at '%s'", response.Code, response.Header().Get("Upload-Offset"))
+			}
+
+			if response = server.patch(location, "5", " world", nil); response.Code != http.StatusConflict {
+				t.Errorf("Expected status 409 for a stale offset, got %d", response.Code)
+			}
+
+			if len(server.completed) != 1 {
+				t.Errorf("Expected the callback to run once, got %d uploads", len(server.completed))
+			}
+		})
+	}
+}
+
+func TestTusHandlerOptions(t *testing.T) {
+	server := newTusTestServer(t, NewMemoryStorage(), WithMaxFileSize(1000))
+	response := server.do(http.MethodOptions, "/files/", nil, "")
+
+	expected := map[string]string{
+		"Tus-Resumable":          "1.0.0",
+		"Tus-Version":            "1.0.0",
+		"Tus-Extension":          "creation,creation-with-upload,termination,expiration,checksum",
+		"Tus-Checksum-Algorithm": "sha1,md5,sha256",
+		"Tus-Max-Size":           "1000",
+	}
+
+	if response.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", response.Code)
+	}
+
+	for key, value := range expected {
+		if got := response.Header().Get(key); got != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, got)
+		}
+	}
+}
+
+func TestTusHandlerCreationWithUpload(t *testing.T) {
+	server := newTusTestServer(t, NewMemoryStorage())
+
+	response := server.do(http.MethodPost, "/files/", map[string]string{
+		"Upload-Length": "5",
+		"Content-Type":  "application/offset+octet-stream",
+	}, "hello")
+
+	if response.Code != http.StatusCreated || response.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("Expected status 201 at offset 5, got %d: %s", response.Code, response.Body.String())
+	}
+
+	if len(server.contents) != 1 || server.contents[0] != "hello" {
+		t.Errorf("Expected the upload to complete with 'hello', got %v", server.contents)
+	}
+}
+
+func TestTusHandlerRejectedRequests(t *testing.T) {
+	server := newTusTestServer(t, NewMemoryStorage(), WithMaxFileSize(100))
+	location := server.create(t, "10", "")
+
+	testCases := []struct {
+		name     string
+		method   string
+		target   string
+		headers  map[string]string
+		body     string
+		expected int
+	}{
+		{"missing length", http.MethodPost, "/files/", nil, "", http.StatusBadRequest},
+		{"too large", http.MethodPost, "/files/", map[string]string{"Upload-Length": "101"}, "", http.StatusRequestEntityTooLarge},
+		{"bad metadata", http.MethodPost, "/files/", map[string]string{"Upload-Length": "1", "Upload-Metadata": "filename !!!"}, "", http.StatusBadRequest},
+		{"wrong version", http.MethodHead, location, map[string]string{"Tus-Resumable": "0.2.2"}, "", http.StatusPreconditionFailed},
+		{"unknown upload", http.MethodHead, "/files/0123456789abcdef0123456789abcdef", nil, "", http.StatusNotFound},
+		{"invalid id", http.MethodHead, "/files/../secret", nil, "", http.StatusNotFound},
+		{"wrong content type", http.MethodPatch, location, map[string]string{"Upload-Offset": "0", "Content-Type": "text/plain"}, "abc", http.StatusUnsupportedMediaType},
+		{"wrong offset", http.MethodPatch, location, map[string]string{"Upload-Offset": "3", "Content-Type": "application/offset+octet-stream"}, "abc", http.StatusConflict},
+		{"chunk too large", http.MethodPatch, location, map[string]string{"Upload-Offset": "0", "Content-Type": "application/offset+octet-stream"}, "0123456789abc", http.StatusRequestEntityTooLarge},
+		{"unsupported checksum", http.MethodPatch, location, map[string]string{"Upload-Offset": "0", "Content-Type": "application/offset+octet-stream", "Upload-Checksum": "crc32 AAAA"}, "abc", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response := server.do(tc.method, tc.target, tc.headers, tc.body)
+
+			if response.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d: %s", tc.expected, response.Code, response.Body.String())
+			}
+
+			if response.Header().Get("Tus-Resumable") != "1.0.0" {
+				t.Errorf("Expected a Tus-Resumable header, got '%s'", response.Header().Get("Tus-Resumable"))
+			}
+		})
+	}
+
+	if response := server.do(http.MethodHead, location, nil, ""); response.Header().Get("Upload-Offset") != "0" {
+		t.Errorf("Expected rejected chunks to leave the offset at 0, got '%s'", response.Header().Get("Upload-Offset"))
+	}
+}
+
+func TestTusHandlerChecksum(t *testing.T) {
+	server := newTusTestServer(t, NewMemoryStorage())
+	location := server.create(t, "6", "")
+
+	sum := sha1.Sum([]byte("abc"))
+	checksum := "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+
+	response := server.patch(location, "0", "abd", map[string]string{"Upload-Checksum": checksum})
+
+	if response.Code != StatusChecksumMismatch {
+		t.Errorf("Expected status %d, got %d", StatusChecksumMismatch, response.Code)
+	}
+
+	response = server.patch(location, "0", "abc", map[string]string{"Upload-Checksum": checksum})
+
+	if response.Code != http.StatusNoContent || response.Header().Get("Upload-Offset") != "3" {
+		t.Errorf("Expected status 204 at offset 3, got %d at '%s'", response.Code, response.Header().Get("Upload-Offset"))
+	}
+}
+
+/*
+brokenBody returns some data and then fails, like a connection that
+drops part way through a request.
+*/
+type brokenBody struct {
+	data string
+	done bool
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if b.done {
+		return 0, errors.New("connection reset")
+	}
+
+	b.done = true
+	return copy(p, b.data), nil
+}
+
+func TestTusHandlerInterruptedChunk(t *testing.T) {
+	server := newTusTestServer(t, NewMemoryStorage())
+	location := server.create(t, "10", "")
+
+	req := httptest.NewRequest(http.MethodPatch, location, &brokenBody{data: "0123"})
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	req.ContentLength = 10
+
+	server.handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if response := server.do(http.MethodHead, location, nil, ""); response.Header().Get("Upload-Offset") != "4" {
+		t.Fatalf("Expected the partial chunk to be kept at offset 4, got '%s'", response.Header().Get("Upload-Offset"))
+	}
+
+	response := server.patch(location, "4", "456789", nil)
+
+	if response.Code != http.StatusNoContent || len(server.contents) != 1 || server.contents[0] != "0123456789" {
+		t.Errorf("Expected the resumed upload to complete, got %d and %v", response.Code, server.contents)
+	}
+}
+
+func TestTusHandlerTermination(t *testing.T) {
+	storage := NewMemoryStorage()
+	server := newTusTestServer(t, storage)
+	location := server.create(t, "10", "")
+
+	server.patch(location, "0", "01234", nil)
+
+	if response := server.do(http.MethodDelete, location, nil, ""); response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", response.Code)
+	}
+
+	if objects, _ := storage.List(context.Background(), ""); len(objects) != 0 {
+		t.Errorf("Expected the upload to be deleted, got %+v", objects)
+	}
+
+	if response := server.do(http.MethodHead, location, nil, ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", response.Code)
+	}
+}
+
+func TestTusHandlerExpiration(t *testing.T) {
+	storage := NewMemoryStorage()
+	server := newTusTestServer(t, storage, WithExpiration(time.Hour))
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	server.handler.now = func() time.Time {
+		return now
+	}
+
+	response := server.do(http.MethodPost, "/files/", map[string]string{"Upload-Length": "10"}, "")
+
+	if response.Header().Get("Upload-Expires") != "Fri, 01 May 2026 11:00:00 GMT" {
+		t.Errorf("Unexpected Upload-Expires '%s'", response.Header().Get("Upload-Expires"))
+	}
+
+	expiring := response.Header().Get("Location")
+	now = now.Add(30 * time.Minute)
+	kept := server.create(t, "10", "")
+	now = now.Add(45 * time.Minute)
+
+	if response = server.patch(expiring, "0", "01234", nil); response.Code != http.StatusGone {
+		t.Errorf("Expected status 410, got %d", response.Code)
+	}
+
+	server.create(t, "10", "")
+	now = now.Add(2 * time.Hour)
+
+	if err := server.handler.CleanupExpired(context.Background()); err != nil {
+		t.Fatalf("CleanupExpired failed: %v", err)
+	}
+
+	if objects, _ := storage.List(context.Background(), ""); len(objects) != 0 {
+		t.Errorf("Expected every upload to be cleaned up, got %+v", objects)
+	}
+
+	if response = server.do(http.MethodHead, kept, nil, ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", response.Code)
+	}
+}
+
+func TestTusHandlerRejectedType(t *testing.T) {
+	storage := NewMemoryStorage()
+	server := newTusTestServer(t, storage, WithAllowedTypes("image/png"))
+	location := server.create(t, "5", tusMetadata("filename", "notes.txt"))
+
+	response := server.patch(location, "0", "hello", nil)
+
+	if response.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415, got %d", response.Code)
+	}
+
+	if len(server.completed) != 0 {
+		t.Errorf("Expected the callback not to run, got %d uploads", len(server.completed))
+	}
+
+	if objects, _ := storage.List(context.Background(), ""); len(objects) != 0 {
+		t.Errorf("Expected the upload to be deleted, got %+v", objects)
+	}
+}
+
+func TestTusHandlerHidesErrors(t *testing.T) {
+	storage := NewMemoryStorage()
+	logged := &strings.Builder{}
+
+	handler := NewTusHandler(storage, "/files/", func(file FileUpload, options *UploadOptions) error {
+		return errors.New("error writing /srv/private/uploads/notes.txt")
+	})
+
+	handler.ErrorLog = log.New(logged, "", 0)
+	server := &tusTestServer{handler: handler}
+	location := server.create(t, "5", tusMetadata("filename", "notes.txt"))
+
+	response := server.patch(location, "0", "hello", nil)
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", response.Code)
+	}
+
+	if strings.Contains(response.Body.String(), "/srv/private") {
+		t.Errorf("Expected a generic message, got '%s'", response.Body.String())
+	}
+
+	if !strings.Contains(logged.String(), "/srv/private") {
+		t.Errorf("Expected the error to be logged, got '%s'", logged.String())
+	}
+}
+
+func TestParseTusMetadata(t *testing.T) {
+	metadata, err := parseTusMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential")
+	if err != nil {
+		t.Fatalf("parseTusMetadata failed: %v", err)
+	}
+
+	if metadata["filename"] != "world_domination_plan.pdf" {
+		t.Errorf("Expected 'world_domination_plan.pdf', got '%s'", metadata["filename"])
+	}
+
+	if value, ok := metadata["is_confidential"]; !ok || value != "" {
+		t.Errorf("Expected an empty is_confidential value, got '%s'", value)
+	}
+}
+
+/*
+listCountingStorage counts calls to List, which should be left to
+CleanupExpired.
+*/
+type listCountingStorage struct {
+	Storage
+	lists int
+}
+
+func (s *listCountingStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	s.lists++
+	return s.Storage.List(ctx, prefix)
+}
+
+func TestTusHandlerDoesNotList(t *testing.T) {
+	storage := &listCountingStorage{Storage: NewLocalStorage(t.TempDir())}
+	server := newTusTestServer(t, storage)
+	location := server.create(t, "11", tusMetadata("filename", "hello.txt"))
+
+	server.patch(location, "0", "hello", nil)
+	server.do(http.MethodHead, location, nil, "")
+
+	if response := server.patch(location, "5", " world", nil); response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", response.Code)
+	}
+
+	server.do(http.MethodHead, location, nil, "")
+	server.do(http.MethodDelete, location, nil, "")
+
+	if storage.lists != 0 {
+		t.Errorf("Expected no calls to List, got %d", storage.lists)
+	}
+
+	if objects, _ := storage.Storage.List(context.Background(), ""); len(objects) != 0 {
+		t.Errorf("Expected the upload to be deleted, got %+v", objects)
+	}
+}
+
+func TestTusHandlerLogsNothingByDefault(t *testing.T) {
+	logged := &strings.Builder{}
+
+	log.SetOutput(logged)
+	defer log.SetOutput(os.Stderr)
+
+	handler := NewTusHandler(NewMemoryStorage(), "/files/", func(file FileUpload, options *UploadOptions) error {
+		return errors.New("storage unavailable")
+	})
+
+	server := &tusTestServer{handler: handler}
+	location := server.create(t, "5", tusMetadata("filename", "notes.txt"))
+
+	if response := server.patch(location, "0", "hello", nil); response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", response.Code)
+	}
+
+	if logged.Len() != 0 {
+		t.Errorf("Expected nothing to be logged, got '%s'", logged.String())
+	}
+}
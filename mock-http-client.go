@@ -1,21 +1,34 @@
 package httphelpers
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"testing"
 )
 
+/*
+MockHttpClient is an HttpClient for tests. Each call to Do is answered by
+an expectation registered with OnDo or OnDoMatching. By default
+expectations are used in the order they were registered; see InAnyOrder.
+*/
 type MockHttpClient struct {
 	mutex     *sync.Mutex
 	callIndex int
-	t         *testing.T
+	unordered bool
+	t         testing.TB
 	Expects   []MockHttpClientDoExpect
 	Calls     []MockHttpClientDoCall
 }
 
 func NewMockHttpClient(t *testing.T) *MockHttpClient {
+	return newMockHttpClient(t)
+}
+
+func newMockHttpClient(t testing.TB) *MockHttpClient {
 	return &MockHttpClient{
 		mutex:   &sync.Mutex{},
 		t:       t,
@@ -29,21 +42,69 @@ type MockHttpClientDoCall struct {
 	Response *MockHttpClientDoExpect
 }
 
+/*
+MockHttpClientDoExpect is a registered response. Matchers select which
+requests it answers; an expectation without matchers answers any request.
+It answers one request unless changed with Times or AnyTimes. When
+Responder is set, a fresh response is built from it for every request
+instead of returning WantResponse.
+*/
 type MockHttpClientDoExpect struct {
 	WantResponse *http.Response
 	WantError    error
+	Responder    *MockResponse
+	Matchers     []RequestMatcher
+
+	times    int
+	timesSet bool
+	anyTimes bool
+	calls    int
+}
+
+/*
+MockHttpClientExpectation configures an expectation after it has been
+registered.
+*/
+type MockHttpClientExpectation struct {
+	client *MockHttpClient
+	index  int
 }
 
 func (m *MockHttpClient) Do(req *http.Request) (*http.Response, error) {
+	var (
+		err  error
+		body []byte
+	)
+
+	if body, err = readRequestBody(req); err != nil {
+		return nil, err
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if m.callIndex >= len(m.Expects) {
+	index, closest, mismatch := m.findExpectation(req, body)
+
+	// Only expectations that allow a fixed number of calls run out, so
+	// their total is every call the mock allows.
+	if closest < 0 {
 		m.t.Errorf("'Do' called more times than expected")
-		return nil, fmt.Errorf("expected %d calls, got %d", len(m.Expects), m.callIndex+1)
+		return nil, fmt.Errorf("expected %d calls, got %d", m.allowedCalls(), m.callIndex+1)
+	}
+
+	if index < 0 {
+		m.t.Errorf("'Do' called with an unexpected request %s %s\nclosest expectation #%d (%s) differs:\n%s", req.Method, req.URL, closest+1, m.Expects[closest].describe(), mismatch)
+		return nil, fmt.Errorf("unexpected request %s %s", req.Method, req.URL)
 	}
 
-	expected := m.Expects[m.callIndex]
+	m.Expects[index].calls++
+	expected := m.Expects[index]
+
+	if expected.Responder != nil {
+		if expected.WantResponse, err = expected.Responder.Build(req); err != nil {
+			return nil, err
+		}
+	}
 
 	m.Calls = append(m.Calls, MockHttpClientDoCall{
 		Request:  req,
@@ -55,18 +116,207 @@ func (m *MockHttpClient) Do(req *http.Request) (*http.Response, error) {
 	return expected.WantResponse, expected.WantError
 }
 
-func (m *MockHttpClient) OnDo(wantResponse *http.Response, wantErr error) {
+/*
+OnDo registers a response for the next request, whatever it is.
+*/
+func (m *MockHttpClient) OnDo(wantResponse *http.Response, wantErr error) *MockHttpClientExpectation {
+	return m.OnDoMatching(wantResponse, wantErr)
+}
+
+/*
+OnDoMatching registers a response for a request that satisfies every
+matcher.
+
+	mock.OnDoMatching(response, nil,
+		httphelpers.MatchMethod(http.MethodPost),
+		httphelpers.MatchPath("/users/*"),
+		httphelpers.MatchJSONBody(`{"name": "Adam"}`),
+	).Times(2)
+*/
+func (m *MockHttpClient) OnDoMatching(wantResponse *http.Response, wantErr error, matchers ...RequestMatcher) *MockHttpClientExpectation {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	m.Expects = append(m.Expects, MockHttpClientDoExpect{WantResponse: wantResponse, WantError: wantErr})
+	m.Expects = append(m.Expects, MockHttpClientDoExpect{
+		WantResponse: wantResponse,
+		WantError:    wantErr,
+		Matchers:     matchers,
+	})
+
+	return &MockHttpClientExpectation{client: m, index: len(m.Expects) - 1}
+}
+
+/*
+OnDoRespond registers a response, built with MockJSON, MockText, MockXML,
+MockFile or MockStatus, for a request that satisfies every matcher.
+
+	mock.OnDoRespond(httphelpers.MockJSON(http.StatusOK, user), httphelpers.MatchPath("/users/*")).AnyTimes()
+*/
+func (m *MockHttpClient) OnDoRespond(response *MockResponse, matchers ...RequestMatcher) *MockHttpClientExpectation {
+	if response.err != nil {
+		m.t.Errorf("invalid mock response: %v", response.err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Expects = append(m.Expects, MockHttpClientDoExpect{
+		Responder: response,
+		Matchers:  matchers,
+	})
+
+	return &MockHttpClientExpectation{client: m, index: len(m.Expects) - 1}
+}
+
+/*
+InAnyOrder lets requests be answered by any expectation that matches
+them, rather than in the order the expectations were registered. Use it
+when the code under test makes requests concurrently.
+*/
+func (m *MockHttpClient) InAnyOrder() *MockHttpClient {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.unordered = true
+	return m
+}
+
+/*
+Times sets how many requests the expectation answers. VerifyCallCount
+reports an expectation that was called a different number of times.
+*/
+func (e *MockHttpClientExpectation) Times(n int) *MockHttpClientExpectation {
+	e.client.mutex.Lock()
+	defer e.client.mutex.Unlock()
+
+	e.client.Expects[e.index].times = n
+	e.client.Expects[e.index].timesSet = true
+	e.client.Expects[e.index].anyTimes = false
+	return e
+}
+
+/*
+AnyTimes lets the expectation answer any number of requests, including
+none.
+*/
+func (e *MockHttpClientExpectation) AnyTimes() *MockHttpClientExpectation {
+	e.client.mutex.Lock()
+	defer e.client.mutex.Unlock()
+
+	e.client.Expects[e.index].anyTimes = true
+	return e
 }
 
 func (m *MockHttpClient) VerifyCallCount() {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if len(m.Expects) != m.callIndex {
-		m.t.Errorf("expected %d calls, but got %d", len(m.Expects), m.callIndex)
+	for index, expect := range m.Expects {
+		if !expect.anyTimes && expect.calls != expect.expectedCalls() {
+			m.t.Errorf("expected %d calls to expectation #%d (%s), but got %d", expect.expectedCalls(), index+1, expect.describe(), expect.calls)
+		}
 	}
 }
+
+/*
+findExpectation returns the index of the expectation that answers a
+request. When none does, it returns -1 along with the index of the
+closest expectation and how it differs, or -1 twice when no expectation
+has calls left. In order, requests go to the first expectation with calls
+left, moving past it only once it has been called as often as it must be.
+*/
+func (m *MockHttpClient) findExpectation(req *http.Request, body []byte) (int, int, string) {
+	var closestMismatches []string
+
+	closest, closestMatched := -1, 0
+
+	for index, expect := range m.Expects {
+		if !expect.anyTimes && expect.calls >= expect.expectedCalls() {
+			continue
+		}
+
+		mismatches := expect.mismatches(req, body)
+
+		if len(mismatches) == 0 {
+			return index, index, ""
+		}
+
+		// The closest expectation is the one with the most matchers satisfied.
+		matched := len(expect.Matchers) - len(mismatches)
+
+		if closest < 0 || matched > closestMatched || (matched == closestMatched && len(mismatches) < len(closestMismatches)) {
+			closest, closestMatched = index, matched
+			closestMismatches = mismatches
+		}
+
+		if !m.unordered && !expect.anyTimes {
+			break
+		}
+	}
+
+	return -1, closest, strings.Join(closestMismatches, "\n")
+}
+
+func (m *MockHttpClient) allowedCalls() int {
+	result := 0
+
+	for _, expect := range m.Expects {
+		result += expect.expectedCalls()
+	}
+
+	return result
+}
+
+func (e MockHttpClientDoExpect) expectedCalls() int {
+	if !e.timesSet {
+		return 1
+	}
+
+	return e.times
+}
+
+func (e MockHttpClientDoExpect) mismatches(req *http.Request, body []byte) []string {
+	result := []string{}
+
+	for _, matcher := range e.Matchers {
+		if mismatch := matcher.Mismatch(req, body); mismatch != "" {
+			result = append(result, mismatch)
+		}
+	}
+
+	return result
+}
+
+func (e MockHttpClientDoExpect) describe() string {
+	if len(e.Matchers) == 0 {
+		return "any request"
+	}
+
+	descriptions := make([]string, 0, len(e.Matchers))
+
+	for _, matcher := range e.Matchers {
+		descriptions = append(descriptions, matcher.String())
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+/*
+readRequestBody reads a request's body and replaces it with a copy, so
+the request can still be sent.
+*/
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,202 @@
+package httphelpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+recordingTB captures the failures a MockHttpClient reports, so tests can
+check them without failing themselves.
+*/
+type recordingTB struct {
+	testing.TB
+	mutex  sync.Mutex
+	errors []string
+}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.errors = append(r.errors, strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func newRecordingMock(t *testing.T) (*MockHttpClient, *recordingTB) {
+	tb := &recordingTB{TB: t}
+	return newMockHttpClient(tb), tb
+}
+
+func statusResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: http.NoBody}
+}
+
+func TestMockHttpClientInOrder(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+	wantErr := errors.New("connection refused")
+
+	mock.OnDo(statusResponse(http.StatusOK), nil)
+	mock.OnDo(nil, wantErr)
+
+	response, err := mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com/a", nil))
+
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Errorf("Expected the first response, got %v and %v", response, err)
+	}
+
+	if _, err = mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com/b", nil)); !errors.Is(err, wantErr) {
+		t.Errorf("Expected the second error, got %v", err)
+	}
+
+	if _, err = mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com/c", nil)); err == nil || err.Error() != "expected 2 calls, got 3" {
+		t.Errorf("Expected an error for an extra call, got %v", err)
+	}
+
+	mock.VerifyCallCount()
+
+	if len(tb.errors) != 1 || tb.errors[0] != "'Do' called more times than expected" {
+		t.Errorf("Unexpected failures %q", tb.errors)
+	}
+
+	if len(mock.Calls) != 2 || mock.Calls[1].Request.URL.Path != "/b" {
+		t.Errorf("Expected 2 recorded calls, got %+v", mock.Calls)
+	}
+}
+
+func TestMockHttpClientMatching(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+
+	mock.OnDoMatching(statusResponse(http.StatusCreated), nil,
+		MatchMethod(http.MethodPost),
+		MatchPath("/users"),
+		MatchJSONBody(`{"name": "Adam", "roles": ["admin"]}`),
+	)
+
+	mock.OnDoMatching(statusResponse(http.StatusOK), nil, MatchMethod(http.MethodGet), MatchPath("/users/*")).Times(2)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/users", strings.NewReader(`{"roles":["admin"],"name":"Adam"}`))
+	response, _ := mock.Do(req)
+
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", response.StatusCode)
+	}
+
+	body, _ := io.ReadAll(mock.Calls[0].Request.Body)
+
+	if string(body) != `{"roles":["admin"],"name":"Adam"}` {
+		t.Errorf("Expected the recorded request body to be readable, got '%s'", body)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if response, _ = mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com/users/"+id, nil)); response.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", response.StatusCode)
+		}
+	}
+
+	mock.VerifyCallCount()
+
+	if len(tb.errors) != 0 {
+		t.Errorf("Unexpected failures %q", tb.errors)
+	}
+
+	if _, err := mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com/users/3", nil)); err == nil || err.Error() != "expected 3 calls, got 4" {
+		t.Errorf("Expected the calls allowed by Times to be counted, got %v", err)
+	}
+}
+
+func TestMockHttpClientInAnyOrder(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+	mock.InAnyOrder()
+
+	paths := []string{"/a", "/b", "/c", "/d"}
+
+	for index, path := range paths {
+		mock.OnDoMatching(statusResponse(200+index), nil, MatchPath(path))
+	}
+
+	wg := sync.WaitGroup{}
+
+	for index := len(paths) - 1; index >= 0; index-- {
+		wg.Go(func() {
+			response, err := mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com"+paths[index], nil))
+
+			if err != nil || response.StatusCode != 200+index {
+				t.Errorf("Expected status %d for %s, got %v and %v", 200+index, paths[index], response, err)
+			}
+		})
+	}
+
+	wg.Wait()
+	mock.VerifyCallCount()
+
+	if len(tb.errors) != 0 {
+		t.Errorf("Unexpected failures %q", tb.errors)
+	}
+}
+
+func TestMockHttpClientAnyTimes(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+
+	mock.OnDoMatching(statusResponse(http.StatusOK), nil, MatchPath("/health")).AnyTimes()
+	mock.OnDoMatching(statusResponse(http.StatusAccepted), nil, MatchPath("/jobs"))
+	mock.OnDoMatching(statusResponse(http.StatusNotFound), nil, MatchPath("/never")).AnyTimes()
+
+	for _, path := range []string{"/health", "/health", "/jobs", "/health"} {
+		if _, err := mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)); err != nil {
+			t.Errorf("Unexpected error for %s: %v", path, err)
+		}
+	}
+
+	mock.VerifyCallCount()
+
+	if len(tb.errors) != 0 {
+		t.Errorf("Unexpected failures %q", tb.errors)
+	}
+}
+
+func TestMockHttpClientFailures(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+	mock.InAnyOrder()
+
+	mock.OnDoMatching(statusResponse(http.StatusOK), nil, MatchMethod(http.MethodGet), MatchPath("/orders"))
+	mock.OnDoMatching(statusResponse(http.StatusOK), nil, MatchMethod(http.MethodPost), MatchPath("/orders"), MatchJSONBody(`{"id": 1, "total": 10}`))
+	mock.OnDoMatching(statusResponse(http.StatusOK), nil, MatchHeader("X-Token", "abc")).Times(2)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/orders", strings.NewReader(`{"id": 1, "total": 12}`))
+
+	if _, err := mock.Do(req); err == nil {
+		t.Errorf("Expected an error for an unexpected request")
+	}
+
+	mock.VerifyCallCount()
+
+	expected := []string{
+		"'Do' called with an unexpected request POST http://example.com/orders\n" +
+			"closest expectation #2 (method POST, path /orders, JSON body {\"id\": 1, \"total\": 10}) differs:\n" +
+			"JSON body:\n" +
+			"  {\n" +
+			"    \"id\": 1,\n" +
+			"-   \"total\": 10\n" +
+			"+   \"total\": 12\n" +
+			"  }",
+		"expected 1 calls to expectation #1 (method GET, path /orders), but got 0",
+		"expected 1 calls to expectation #2 (method POST, path /orders, JSON body {\"id\": 1, \"total\": 10}), but got 0",
+		"expected 2 calls to expectation #3 (header X-Token: abc), but got 0",
+	}
+
+	if len(tb.errors) != len(expected) {
+		t.Fatalf("Expected %d failures, got %q", len(expected), tb.errors)
+	}
+
+	for index, want := range expected {
+		if tb.errors[index] != want {
+			t.Errorf("Expected failure:\n%s\ngot:\n%s", want, tb.errors[index])
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package httphelpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/*
+MockResponse builds the responses a MockHttpClient returns. A new
+*http.Response, with its own unread body, is built for every request the
+expectation answers, so responses used with Times or AnyTimes can be
+read each time.
+*/
+type MockResponse struct {
+	status  int
+	header  http.Header
+	cookies []*http.Cookie
+	body    []byte
+	err     error
+}
+
+/*
+MockStatus builds a response with the given status and no body.
+*/
+func MockStatus(status int) *MockResponse {
+	return &MockResponse{status: status, header: http.Header{}}
+}
+
+/*
+MockText builds a plain text response.
+*/
+func MockText(status int, text string) *MockResponse {
+	return MockStatus(status).withBody([]byte(text), "text/plain; charset=utf-8")
+}
+
+/*
+MockJSON builds a response with value encoded as JSON.
+*/
+func MockJSON(status int, value any) *MockResponse {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return &MockResponse{err: fmt.Errorf("error encoding mock JSON response: %w", err)}
+	}
+
+	return MockStatus(status).withBody(body, "application/json")
+}
+
+/*
+MockXML builds a response with value encoded as XML, including the XML
+declaration.
+*/
+func MockXML(status int, value any) *MockResponse {
+	body, err := xml.Marshal(value)
+	if err != nil {
+		return &MockResponse{err: fmt.Errorf("error encoding mock XML response: %w", err)}
+	}
+
+	return MockStatus(status).withBody(append([]byte(xml.Header), body...), "application/xml")
+}
+
+/*
+MockFile builds a 200 OK response from a file, such as a golden file in
+testdata. The content type is taken from the file's extension, or sniffed
+from its content when the extension is unknown. The file is read once,
+when MockFile is called.
+*/
+func MockFile(fileName string) *MockResponse {
+	body, err := os.ReadFile(fileName)
+	if err != nil {
+		return &MockResponse{err: fmt.Errorf("error reading mock response file: %w", err)}
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	return MockStatus(http.StatusOK).withBody(body, contentType)
+}
+
+/*
+WithStatus changes the response status.
+*/
+func (r *MockResponse) WithStatus(status int) *MockResponse {
+	r.status = status
+	return r
+}
+
+/*
+WithHeader adds a header value to the response.
+*/
+func (r *MockResponse) WithHeader(key, value string) *MockResponse {
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+
+	r.header.Add(key, value)
+	return r
+}
+
+/*
+WithCookie adds a Set-Cookie header to the response.
+*/
+func (r *MockResponse) WithCookie(cookie *http.Cookie) *MockResponse {
+	r.cookies = append(r.cookies, cookie)
+	return r
+}
+
+/*
+Build creates a response to req. Request, ContentLength and the protocol
+fields are set the way net/http sets them, and responses to HEAD requests
+have an empty body.
+*/
+func (r *MockResponse) Build(req *http.Request) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	header := r.header.Clone()
+
+	if header == nil {
+		header = http.Header{}
+	}
+
+	for _, cookie := range r.cookies {
+		header.Add("Set-Cookie", cookie.String())
+	}
+
+	if len(r.body) > 0 {
+		header.Set("Content-Length", strconv.Itoa(len(r.body)))
+	}
+
+	var body io.ReadCloser = http.NoBody
+
+	if len(r.body) > 0 && (req == nil || req.Method != http.MethodHead) {
+		body = io.NopCloser(bytes.NewReader(r.body))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *MockResponse) withBody(body []byte, contentType string) *MockResponse {
+	r.body = body
+	r.header.Set("Content-Type", contentType)
+
+	return r
+}
@@ -0,0 +1,139 @@
+package httphelpers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMockResponseBuilders(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	dir := t.TempDir()
+	goldenFile := filepath.Join(dir, "users.json")
+
+	if err := os.WriteFile(goldenFile, []byte(`[{"name":"Adam"}]`), 0o644); err != nil {
+		t.Fatalf("Failed to write golden file: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		response    *MockResponse
+		status      string
+		contentType string
+		body        string
+	}{
+		{"json", MockJSON(http.StatusOK, user{Name: "Adam"}), "200 OK", "application/json", `{"name":"Adam"}`},
+		{"text", MockText(http.StatusTeapot, "short and stout"), "418 I'm a teapot", "text/plain; charset=utf-8", "short and stout"},
+		{"xml", MockXML(http.StatusCreated, user{Name: "Adam"}), "201 Created", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<user><name>Adam</name></user>"},
+		{"file", MockFile(goldenFile), "200 OK", "application/json", `[{"name":"Adam"}]`},
+		{"status", MockStatus(http.StatusNoContent), "204 No Content", "", ""},
+		{"file with status", MockFile(goldenFile).WithStatus(http.StatusAccepted), "202 Accepted", "application/json", `[{"name":"Adam"}]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+
+			response, err := tc.response.Build(req)
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+
+			body, _ := io.ReadAll(response.Body)
+
+			if response.Status != tc.status || response.Header.Get("Content-Type") != tc.contentType || string(body) != tc.body {
+				t.Errorf("Expected %s %s '%s', got %s %s '%s'", tc.status, tc.contentType, tc.body, response.Status, response.Header.Get("Content-Type"), body)
+			}
+
+			if response.ContentLength != int64(len(tc.body)) || response.Proto != "HTTP/1.1" || response.ProtoMajor != 1 || response.ProtoMinor != 1 || response.Request != req {
+				t.Errorf("Unexpected response fields %+v", response)
+			}
+		})
+	}
+}
+
+func TestMockResponseHeadersAndCookies(t *testing.T) {
+	response, _ := MockStatus(http.StatusFound).
+		WithHeader("Location", "/login").
+		WithCookie(&http.Cookie{Name: "session", Value: "abc", HttpOnly: true}).
+		Build(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if response.Header.Get("Location") != "/login" {
+		t.Errorf("Expected the Location header, got '%s'", response.Header.Get("Location"))
+	}
+
+	cookies := response.Cookies()
+
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "abc" || !cookies[0].HttpOnly {
+		t.Errorf("Unexpected cookies %+v", cookies)
+	}
+}
+
+func TestMockResponseWithoutHeader(t *testing.T) {
+	response, err := (&MockResponse{status: http.StatusOK}).
+		WithCookie(&http.Cookie{Name: "session", Value: "abc"}).
+		WithHeader("X-Request-Id", "42").
+		Build(nil)
+
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if response.Header.Get("X-Request-Id") != "42" || len(response.Cookies()) != 1 {
+		t.Errorf("Expected the header and cookie, got %v", response.Header)
+	}
+}
+
+func TestMockResponseHead(t *testing.T) {
+	response, _ := MockText(http.StatusOK, "hello").Build(httptest.NewRequest(http.MethodHead, "/", nil))
+	body, _ := io.ReadAll(response.Body)
+
+	if len(body) != 0 || response.ContentLength != 5 {
+		t.Errorf("Expected an empty body with a content length of 5, got '%s' and %d", body, response.ContentLength)
+	}
+}
+
+func TestMockFileMissing(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+	mock.OnDoRespond(MockFile(filepath.Join(t.TempDir(), "missing.json")))
+
+	if _, err := mock.Do(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if len(tb.errors) != 1 {
+		t.Errorf("Expected the invalid response to be reported, got %q", tb.errors)
+	}
+}
+
+func TestMockHttpClientFreshBodies(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+	mock.OnDoRespond(MockText(http.StatusOK, "hello"), MatchPath("/greeting")).Times(2)
+
+	for range 2 {
+		response, err := mock.Do(httptest.NewRequest(http.MethodGet, "http://example.com/greeting", nil))
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+
+		if body, _ := io.ReadAll(response.Body); string(body) != "hello" {
+			t.Errorf("Expected 'hello' on every call, got '%s'", body)
+		}
+	}
+
+	if mock.Calls[1].Response.WantResponse == nil || mock.Calls[0].Response.WantResponse == mock.Calls[1].Response.WantResponse {
+		t.Errorf("Expected each call to record its own response")
+	}
+
+	mock.VerifyCallCount()
+
+	if len(tb.errors) != 0 {
+		t.Errorf("Unexpected failures %q", tb.errors)
+	}
+}
@@ -0,0 +1,245 @@
+package httphelpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+/*
+RequestMatcher decides whether a request matches a MockHttpClient
+expectation. Mismatch returns an empty string when the request matches,
+and otherwise explains how it differs. body is the request body, which
+has already been read.
+*/
+type RequestMatcher interface {
+	String() string
+	Mismatch(req *http.Request, body []byte) string
+}
+
+type requestMatcher struct {
+	description string
+	mismatch    func(req *http.Request, body []byte) string
+}
+
+func (m requestMatcher) String() string {
+	return m.description
+}
+
+func (m requestMatcher) Mismatch(req *http.Request, body []byte) string {
+	return m.mismatch(req, body)
+}
+
+/*
+MatchMethod matches requests made with the given HTTP method.
+*/
+func MatchMethod(method string) RequestMatcher {
+	return requestMatcher{
+		description: "method " + method,
+		mismatch: func(req *http.Request, body []byte) string {
+			if strings.EqualFold(req.Method, method) {
+				return ""
+			}
+
+			return fmt.Sprintf("method: want %s, got %s", method, req.Method)
+		},
+	}
+}
+
+/*
+MatchURL matches the full request URL against a pattern, such as
+"https://api.example.com/users/*". Patterns use the syntax of path.Match,
+so * does not match across a slash.
+*/
+func MatchURL(pattern string) RequestMatcher {
+	return requestMatcher{
+		description: "URL " + pattern,
+		mismatch: func(req *http.Request, body []byte) string {
+			return matchPattern("URL", pattern, req.URL.String())
+		},
+	}
+}
+
+/*
+MatchPath matches the request path against a pattern, such as
+"/users/*". Patterns use the syntax of path.Match.
+*/
+func MatchPath(pattern string) RequestMatcher {
+	return requestMatcher{
+		description: "path " + pattern,
+		mismatch: func(req *http.Request, body []byte) string {
+			return matchPattern("path", pattern, req.URL.Path)
+		},
+	}
+}
+
+/*
+MatchQuery matches requests whose query string has the given value for
+key.
+*/
+func MatchQuery(key, value string) RequestMatcher {
+	return requestMatcher{
+		description: fmt.Sprintf("query %s=%s", key, value),
+		mismatch: func(req *http.Request, body []byte) string {
+			values := req.URL.Query()[key]
+
+			if slices.Contains(values, value) {
+				return ""
+			}
+
+			return fmt.Sprintf("query %s: want %q, got %q", key, value, values)
+		},
+	}
+}
+
+/*
+MatchHeader matches requests with the given value for a header.
+*/
+func MatchHeader(key, value string) RequestMatcher {
+	return requestMatcher{
+		description: fmt.Sprintf("header %s: %s", key, value),
+		mismatch: func(req *http.Request, body []byte) string {
+			values := req.Header.Values(key)
+
+			if slices.Contains(values, value) {
+				return ""
+			}
+
+			return fmt.Sprintf("header %s: want %q, got %q", key, value, values)
+		},
+	}
+}
+
+/*
+MatchBody matches requests whose body is exactly want.
+*/
+func MatchBody(want string) RequestMatcher {
+	return requestMatcher{
+		description: "body " + want,
+		mismatch: func(req *http.Request, body []byte) string {
+			if string(body) == want {
+				return ""
+			}
+
+			return "body:\n" + lineDiff(want, string(body))
+		},
+	}
+}
+
+/*
+MatchJSONBody matches requests whose body is JSON equivalent to want,
+ignoring key order and whitespace.
+*/
+func MatchJSONBody(want string) RequestMatcher {
+	var wantValue any
+
+	wantErr := json.Unmarshal([]byte(want), &wantValue)
+
+	return requestMatcher{
+		description: "JSON body " + want,
+		mismatch: func(req *http.Request, body []byte) string {
+			var gotValue any
+
+			if wantErr != nil {
+				return fmt.Sprintf("JSON body: expected JSON is invalid: %v", wantErr)
+			}
+
+			if err := json.Unmarshal(body, &gotValue); err != nil {
+				return fmt.Sprintf("JSON body: request body is not valid JSON: %v\n%s", err, body)
+			}
+
+			if reflect.DeepEqual(wantValue, gotValue) {
+				return ""
+			}
+
+			wantJSON, _ := json.MarshalIndent(wantValue, "", "  ")
+			gotJSON, _ := json.MarshalIndent(gotValue, "", "  ")
+
+			return "JSON body:\n" + lineDiff(string(wantJSON), string(gotJSON))
+		},
+	}
+}
+
+/*
+MatchFunc matches requests for which predicate returns true. The request
+body has been read, but can be read again. description is shown in
+failure messages.
+*/
+func MatchFunc(description string, predicate func(req *http.Request, body []byte) bool) RequestMatcher {
+	return requestMatcher{
+		description: description,
+		mismatch: func(req *http.Request, body []byte) string {
+			if predicate(req, body) {
+				return ""
+			}
+
+			return description + ": predicate returned false"
+		},
+	}
+}
+
+func matchPattern(name, pattern, value string) string {
+	matched, err := path.Match(pattern, value)
+
+	if err != nil {
+		return fmt.Sprintf("%s: invalid pattern %q: %v", name, pattern, err)
+	}
+
+	if matched {
+		return ""
+	}
+
+	return fmt.Sprintf("%s: want %s, got %s", name, pattern, value)
+}
+
+/*
+lineDiff compares two texts line by line, marking lines only in want with
+"-" and lines only in got with "+".
+*/
+func lineDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	// lengths[i][j] is the longest common subsequence of wantLines[i:] and gotLines[j:].
+	lengths := make([][]int, len(wantLines)+1)
+
+	for i := range lengths {
+		lengths[i] = make([]int, len(gotLines)+1)
+	}
+
+	for i := len(wantLines) - 1; i >= 0; i-- {
+		for j := len(gotLines) - 1; j >= 0; j-- {
+			if wantLines[i] == gotLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	result := strings.Builder{}
+	i, j := 0, 0
+
+	for i < len(wantLines) || j < len(gotLines) {
+		switch {
+		case i < len(wantLines) && j < len(gotLines) && wantLines[i] == gotLines[j]:
+			result.WriteString("  " + wantLines[i] + "\n")
+			i++
+			j++
+
+		case i < len(wantLines) && (j == len(gotLines) || lengths[i+1][j] >= lengths[i][j+1]):
+			result.WriteString("- " + wantLines[i] + "\n")
+			i++
+
+		default:
+			result.WriteString("+ " + gotLines[j] + "\n")
+			j++
+		}
+	}
+
+	return result.String()
+}
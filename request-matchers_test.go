@@ -0,0 +1,65 @@
+package httphelpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestMatchers(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "https://api.example.com/users/42?expand=roles&expand=teams", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	body := []byte(`{"name":"Adam"}`)
+
+	testCases := []struct {
+		name     string
+		matcher  RequestMatcher
+		expected string
+	}{
+		{"method", MatchMethod("put"), ""},
+		{"wrong method", MatchMethod(http.MethodPost), "method: want POST, got PUT"},
+		{"url", MatchURL("https://api.example.com/users/*"), ""},
+		{"wrong url", MatchURL("https://api.example.com/teams/*"), "URL: want https://api.example.com/teams/*, got https://api.example.com/users/42?expand=roles&expand=teams"},
+		{"path", MatchPath("/users/*"), ""},
+		{"invalid pattern", MatchPath("/users/["), "path: invalid pattern \"/users/[\": syntax error in pattern"},
+		{"query", MatchQuery("expand", "teams"), ""},
+		{"wrong query", MatchQuery("expand", "owner"), "query expand: want \"owner\", got [\"roles\" \"teams\"]"},
+		{"header", MatchHeader("authorization", "Bearer token"), ""},
+		{"missing header", MatchHeader("X-Request-Id", "1"), "header X-Request-Id: want \"1\", got []"},
+		{"body", MatchBody(`{"name":"Adam"}`), ""},
+		{"wrong body", MatchBody(`{"name":"Eve"}`), "body:\n- {\"name\":\"Eve\"}\n+ {\"name\":\"Adam\"}\n"},
+		{"json body", MatchJSONBody(` { "name" : "Adam" } `), ""},
+		{"func", MatchFunc("short body", func(req *http.Request, body []byte) bool { return len(body) < 20 }), ""},
+		{"failing func", MatchFunc("empty body", func(req *http.Request, body []byte) bool { return len(body) == 0 }), "empty body: predicate returned false"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.matcher.Mismatch(req, body); got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMatchJSONBodyInvalid(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	if got := MatchJSONBody(`{"a": 1}`).Mismatch(req, []byte("not json")); !strings.HasPrefix(got, "JSON body: request body is not valid JSON") {
+		t.Errorf("Unexpected mismatch '%s'", got)
+	}
+
+	if got := MatchJSONBody(`{`).Mismatch(req, []byte("{}")); !strings.HasPrefix(got, "JSON body: expected JSON is invalid") {
+		t.Errorf("Unexpected mismatch '%s'", got)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	expected := "  a\n- b\n+ x\n  c\n+ d\n"
+
+	if got := lineDiff("a\nb\nc", "a\nx\nc\nd"); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,437 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Source identifies where in a request a value was read from.
+*/
+type Source string
+
+const (
+	SourceQuery  Source = "query"
+	SourceForm   Source = "form"
+	SourcePath   Source = "path"
+	SourceHeader Source = "header"
+	SourceCookie Source = "cookie"
+	SourceBody   Source = "body"
+)
+
+var bindSources = []Source{SourceQuery, SourceForm, SourcePath, SourceHeader, SourceCookie}
+
+/*
+FieldError describes a single request value that could not be converted
+to its destination type. Index is the position of the offending element
+when the destination is a slice, and -1 otherwise. Field is the name of
+the struct field being bound, and is only set by Bind.
+*/
+type FieldError struct {
+	Field  string
+	Name   string
+	Source Source
+	Value  string
+	Index  int
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	var (
+		numErr *strconv.NumError
+	)
+
+	message := strings.Builder{}
+
+	if e.Source == SourceBody {
+		message.WriteString("invalid request body")
+	} else {
+		message.WriteString(fmt.Sprintf("invalid value %q", e.Value))
+
+		if e.Index >= 0 {
+			message.WriteString(fmt.Sprintf(" at index %d", e.Index))
+		}
+
+		message.WriteString(fmt.Sprintf(" for %s parameter %q", e.Source, e.Name))
+	}
+
+	if e.Field != "" {
+		message.WriteString(fmt.Sprintf(" (field %s)", e.Field))
+	}
+
+	if errors.As(e.Err, &numErr) {
+		message.WriteString(": " + numErr.Err.Error())
+	} else if e.Err != nil {
+		message.WriteString(": " + e.Err.Error())
+	}
+
+	return message.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+/*
+FieldErrors is a collection of FieldError. It is returned when one or
+more request values fail to convert.
+*/
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+/*
+FieldMessages groups the error messages by parameter name. Body errors
+are grouped under "body".
+*/
+func (e FieldErrors) FieldMessages() map[string][]string {
+	result := make(map[string][]string)
+
+	for _, fieldErr := range e {
+		name := fieldErr.Name
+
+		if fieldErr.Source == SourceBody {
+			name = string(SourceBody)
+		}
+
+		result[name] = append(result[name], fieldErr.Error())
+	}
+
+	return result
+}
+
+func (e FieldErrors) Unwrap() []error {
+	result := make([]error, 0, len(e))
+
+	for _, fieldErr := range e {
+		result = append(result, fieldErr)
+	}
+
+	return result
+}
+
+/*
+Bind fills a new struct of type T from the request. Each exported field
+declares where its value comes from using one of the following tags:
+
+  - query:"name" reads URL query parameters
+  - form:"name" reads form data, falling back to URL query parameters
+  - path:"name" reads path parameters
+  - header:"Name" reads request headers
+  - cookie:"name" reads cookies
+  - body:"" decodes the request body (JSON or XML) into the field
+
+Fields support the same types as Get, and pointers to them. Embedded
+structs are bound recursively. Values that are missing leave the field
+untouched. If any value fails to convert, a FieldErrors listing every
+failure is returned.
+*/
+func Bind[T any](r *http.Request) (T, error) {
+	var (
+		err    error
+		result T
+	)
+
+	value := reflect.ValueOf(&result).Elem()
+
+	if value.Kind() != reflect.Struct {
+		return result, fmt.Errorf("cannot bind request to non-struct type %T", result)
+	}
+
+	b := &binder{r: r}
+
+	if err = b.bindStruct(value); err != nil {
+		return result, err
+	}
+
+	if len(b.errors) > 0 {
+		return result, b.errors
+	}
+
+	return result, nil
+}
+
+type binder struct {
+	r          *http.Request
+	query      url.Values
+	formParsed bool
+	body       []byte
+	bodyRead   bool
+	errors     FieldErrors
+}
+
+func (b *binder) bindStruct(value reflect.Value) error {
+	var (
+		err error
+	)
+
+	structType := value.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		fieldValue := value.Field(i)
+
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		if _, ok := field.Tag.Lookup(string(SourceBody)); ok {
+			if err = b.bindBody(field, fieldValue); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		source, name, ok := bindTag(field)
+
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err = b.bindStruct(fieldValue); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if !fieldValue.CanSet() {
+			continue
+		}
+
+		var values []string
+
+		if values, err = b.values(source, name); err != nil {
+			return err
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		for _, convErr := range setValue(fieldValue, values) {
+			b.errors = append(b.errors, &FieldError{
+				Field:  field.Name,
+				Name:   name,
+				Source: source,
+				Value:  convErr.value,
+				Index:  convErr.index,
+				Err:    convErr.err,
+			})
+		}
+	}
+
+	return nil
+}
+
+func (b *binder) bindBody(field reflect.StructField, fieldValue reflect.Value) error {
+	var (
+		err error
+	)
+
+	if !fieldValue.CanSet() {
+		return nil
+	}
+
+	if !b.bodyRead {
+		b.bodyRead = true
+
+		if b.r.Body != nil {
+			if b.body, err = io.ReadAll(b.r.Body); err != nil {
+				return fmt.Errorf("error reading request body: %w", err)
+			}
+		}
+	}
+
+	if len(b.body) == 0 {
+		return nil
+	}
+
+	// The body is left out of the error, since validation messages are
+	// often sent back to the client.
+	if err = decodeBody(b.r.Header.Get("Content-Type"), b.body, fieldValue.Addr().Interface()); err != nil {
+		b.errors = append(b.errors, &FieldError{
+			Field:  field.Name,
+			Source: SourceBody,
+			Index:  -1,
+			Err:    err,
+		})
+	}
+
+	return nil
+}
+
+func (b *binder) values(source Source, name string) ([]string, error) {
+	var (
+		err    error
+		cookie *http.Cookie
+	)
+
+	switch source {
+	case SourceQuery:
+		if b.query == nil {
+			b.query = b.r.URL.Query()
+		}
+
+		return b.query[name], nil
+
+	case SourceForm:
+		if !b.formParsed {
+			b.formParsed = true
+
+			if err = b.r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+				return nil, fmt.Errorf("error parsing form data: %w", err)
+			}
+		}
+
+		return b.r.Form[name], nil
+
+	case SourcePath:
+		if value := b.r.PathValue(name); value != "" {
+			return []string{value}, nil
+		}
+
+	case SourceHeader:
+		return b.r.Header.Values(name), nil
+
+	case SourceCookie:
+		if cookie, err = b.r.Cookie(name); err == nil {
+			return []string{cookie.Value}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func bindTag(field reflect.StructField) (Source, string, bool) {
+	for _, source := range bindSources {
+		if name, ok := field.Tag.Lookup(string(source)); ok {
+			if name == "-" {
+				return "", "", false
+			}
+
+			if name == "" {
+				name = field.Name
+			}
+
+			return source, name, true
+		}
+	}
+
+	return "", "", false
+}
+
+type conversionError struct {
+	index int
+	value string
+	err   error
+}
+
+/*
+setValue converts raw request values into target. Scalars use the first
+value, while slices convert every value and report failures by index.
+*/
+func setValue(target reflect.Value, values []string) []conversionError {
+	var (
+		err error
+	)
+
+	switch target.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(target.Type().Elem())
+
+		if errs := setValue(elem.Elem(), values); len(errs) > 0 {
+			return errs
+		}
+
+		target.Set(elem)
+		return nil
+
+	case reflect.Slice:
+		result := reflect.MakeSlice(target.Type(), 0, len(values))
+		errs := []conversionError{}
+
+		for index, raw := range values {
+			elem := reflect.New(target.Type().Elem()).Elem()
+
+			if err = setScalar(elem, raw); err != nil {
+				errs = append(errs, conversionError{index: index, value: raw, err: err})
+				continue
+			}
+
+			result = reflect.Append(result, elem)
+		}
+
+		target.Set(result)
+		return errs
+
+	default:
+		if err = setScalar(target, values[0]); err != nil {
+			return []conversionError{{index: -1, value: values[0], err: err}}
+		}
+	}
+
+	return nil
+}
+
+func setScalar(target reflect.Value, raw string) error {
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+
+	case reflect.Bool:
+		result, err := strconv.ParseBool(raw)
+
+		if err != nil {
+			return err
+		}
+
+		target.SetBool(result)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result, err := strconv.ParseInt(raw, 10, target.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		target.SetInt(result)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		result, err := strconv.ParseUint(raw, 10, target.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		target.SetUint(result)
+
+	case reflect.Float32, reflect.Float64:
+		result, err := strconv.ParseFloat(raw, target.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		target.SetFloat(result)
+
+	default:
+		return fmt.Errorf("unsupported type %s", target.Type())
+	}
+
+	return nil
+}
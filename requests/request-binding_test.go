@@ -0,0 +1,200 @@
+package requests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bindTestPagination struct {
+	Page     int `query:"page"`
+	PageSize int `query:"pageSize"`
+}
+
+type bindTestRequest struct {
+	bindTestPagination
+
+	ID       uint64           `path:"id"`
+	Name     string           `form:"name"`
+	Tags     []string         `form:"tag"`
+	Scores   []float64        `form:"score"`
+	Tenant   string           `header:"X-Tenant"`
+	Session  string           `cookie:"sid"`
+	Active   *bool            `query:"active"`
+	Payload  BodyTestPayload  `body:""`
+	Ignored  string           `query:"-"`
+	Optional *BodyTestPayload `body:""`
+}
+
+func TestBind(t *testing.T) {
+	t.Run("AllSources", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Adam")
+		form.Add("tag", "a")
+		form.Add("tag", "b")
+		form.Add("score", "1.5")
+
+		req := httptest.NewRequest("POST", "/items/42?page=3&pageSize=25&active=true&Ignored=x", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Tenant", "acme")
+		req.AddCookie(&http.Cookie{Name: "sid", Value: "session-id"})
+		req.SetPathValue("id", "42")
+
+		got, err := Bind[bindTestRequest](req)
+		if err != nil {
+			t.Fatalf("Bind failed: %v", err)
+		}
+
+		if got.Page != 3 || got.PageSize != 25 {
+			t.Errorf("Expected embedded pagination 3/25, got %d/%d", got.Page, got.PageSize)
+		}
+
+		if got.ID != 42 {
+			t.Errorf("Expected ID 42, got %d", got.ID)
+		}
+
+		if got.Name != "Adam" {
+			t.Errorf("Expected name 'Adam', got '%s'", got.Name)
+		}
+
+		if !reflect.DeepEqual(got.Tags, []string{"a", "b"}) {
+			t.Errorf("Expected tags [a b], got %v", got.Tags)
+		}
+
+		if !reflect.DeepEqual(got.Scores, []float64{1.5}) {
+			t.Errorf("Expected scores [1.5], got %v", got.Scores)
+		}
+
+		if got.Tenant != "acme" {
+			t.Errorf("Expected tenant 'acme', got '%s'", got.Tenant)
+		}
+
+		if got.Session != "session-id" {
+			t.Errorf("Expected session 'session-id', got '%s'", got.Session)
+		}
+
+		if got.Active == nil || !*got.Active {
+			t.Errorf("Expected active to be true, got %v", got.Active)
+		}
+
+		if got.Ignored != "" {
+			t.Errorf("Expected ignored field to be empty, got '%s'", got.Ignored)
+		}
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"Adam","age":30}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		got, err := Bind[bindTestRequest](req)
+		if err != nil {
+			t.Fatalf("Bind failed: %v", err)
+		}
+
+		expected := BodyTestPayload{Name: "Adam", Age: 30}
+
+		if !reflect.DeepEqual(got.Payload, expected) {
+			t.Errorf("Expected payload %v, got %v", expected, got.Payload)
+		}
+
+		if got.Optional == nil || !reflect.DeepEqual(*got.Optional, expected) {
+			t.Errorf("Expected optional payload %v, got %v", expected, got.Optional)
+		}
+	})
+
+	t.Run("MissingValuesAreZero", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		got, err := Bind[bindTestRequest](req)
+		if err != nil {
+			t.Fatalf("Bind failed: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, bindTestRequest{}) {
+			t.Errorf("Expected zero value, got %+v", got)
+		}
+	})
+
+	t.Run("AggregatedErrors", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/?page=abc&active=maybe", strings.NewReader("score=1&score=x&score=2&score=y"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", "-1")
+
+		_, err := Bind[bindTestRequest](req)
+		if err == nil {
+			t.Fatal("Expected an error, but got nil")
+		}
+
+		var fieldErrors FieldErrors
+
+		if !errors.As(err, &fieldErrors) {
+			t.Fatalf("Expected FieldErrors, got %T", err)
+		}
+
+		type failure struct {
+			field  string
+			source Source
+			value  string
+			index  int
+		}
+
+		expected := []failure{
+			{"Page", SourceQuery, "abc", -1},
+			{"ID", SourcePath, "-1", -1},
+			{"Scores", SourceForm, "x", 1},
+			{"Scores", SourceForm, "y", 3},
+			{"Active", SourceQuery, "maybe", -1},
+		}
+
+		got := []failure{}
+
+		for _, fieldErr := range fieldErrors {
+			got = append(got, failure{fieldErr.Field, fieldErr.Source, fieldErr.Value, fieldErr.Index})
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected failures %v, got %v", expected, got)
+		}
+
+		if !strings.Contains(err.Error(), `invalid value "abc" for query parameter "page"`) {
+			t.Errorf("Expected error message to describe the page failure, got: %v", err)
+		}
+
+		var fieldErr *FieldError
+
+		if !errors.As(err, &fieldErr) || fieldErr.Name != "page" {
+			t.Errorf("Expected errors.As to find the first FieldError, got %v", fieldErr)
+		}
+
+		messages := fieldErrors.FieldMessages()
+
+		if len(messages["score"]) != 2 || len(messages["page"]) != 1 {
+			t.Errorf("Expected messages grouped by parameter name, got %v", messages)
+		}
+	})
+
+	t.Run("BadBody", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":`))
+		req.Header.Set("Content-Type", "application/json")
+
+		_, err := Bind[bindTestRequest](req)
+
+		var fieldErr *FieldError
+
+		if !errors.As(err, &fieldErr) || fieldErr.Source != SourceBody {
+			t.Fatalf("Expected a body FieldError, got %v", err)
+		}
+	})
+
+	t.Run("NonStruct", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		if _, err := Bind[int](req); err == nil {
+			t.Fatal("Expected an error binding to a non-struct, but got nil")
+		}
+	})
+}
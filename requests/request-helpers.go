@@ -5,7 +5,9 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 )
@@ -41,19 +43,8 @@ func Body[T any](r *http.Request) (T, error) {
 		return result, fmt.Errorf("error reading request body: %w", err)
 	}
 
-	switch r.Header.Get("Content-Type") {
-	case "application/json":
-		if err = json.Unmarshal(b, &result); err != nil {
-			return result, fmt.Errorf("error unmarshaling body to destination: %w, contents: %s", err, string(b))
-		}
-
-	case "application/xml":
-		if err = xml.Unmarshal(b, &result); err != nil {
-			return result, fmt.Errorf("error unmarshaling body to destination: %w, contents: %s", err, string(b))
-		}
-
-	default:
-		return result, fmt.Errorf("unsupported content type: %s", r.Header.Get("Content-Type"))
+	if err = unmarshalBody(r.Header.Get("Content-Type"), b, &result); err != nil {
+		return result, err
 	}
 
 	return result, nil
@@ -211,6 +202,113 @@ func Get[T RequestTypes](r *http.Request, name string) T {
 	}
 }
 
+/*
+GetE is the error-returning variant of Get. It behaves exactly like Lookup,
+and exists so the two read naturally side by side.
+*/
+func GetE[T RequestTypes](r *http.Request, name string) (T, bool, error) {
+	return Lookup[T](r, name)
+}
+
+/*
+GetOr retrieves a value from the request's form or path parameters, returning
+defaultValue when the parameter is missing or cannot be converted.
+*/
+func GetOr[T RequestTypes](r *http.Request, name string, defaultValue T) T {
+	result, found, err := Lookup[T](r, name)
+
+	if !found || err != nil {
+		return defaultValue
+	}
+
+	return result
+}
+
+/*
+Lookup retrieves a value from the request's form or path parameters, using
+the same precedence and types as Get. As with Get, a number in the form
+that cannot be converted gives way to a path parameter of the same name.
+Unlike Get, it reports whether the parameter was present and returns an
+error when it cannot be converted.
+
+For scalar types, a conversion failure is returned as a *FieldError. For
+slice types, every bad element is reported in a FieldErrors, each entry
+carrying the index of the element, and the returned slice contains the
+elements that did convert.
+*/
+func Lookup[T RequestTypes](r *http.Request, name string) (T, bool, error) {
+	var (
+		result T
+	)
+
+	target := reflect.ValueOf(&result).Elem()
+	source := SourceForm
+	values := []string{}
+
+	if value := r.FormValue(name); value != "" {
+		values = append(values, value)
+	}
+
+	if target.Kind() == reflect.Slice {
+		values = r.Form[name]
+	}
+
+	if len(values) == 0 {
+		if value := r.PathValue(name); value != "" {
+			source = SourcePath
+			values = []string{value}
+		}
+	}
+
+	if len(values) == 0 {
+		return result, false, nil
+	}
+
+	convErrs := setValue(target, values)
+
+	if len(convErrs) > 0 && source == SourceForm && isNumberKind(target.Kind()) {
+		if value := r.PathValue(name); value != "" {
+			pathValue := reflect.New(target.Type()).Elem()
+
+			if len(setValue(pathValue, []string{value})) == 0 {
+				target.Set(pathValue)
+				convErrs = nil
+			}
+		}
+	}
+
+	if len(convErrs) == 0 {
+		return result, true, nil
+	}
+
+	fieldErrors := FieldErrors{}
+
+	for _, convErr := range convErrs {
+		fieldErrors = append(fieldErrors, &FieldError{
+			Name:   name,
+			Source: source,
+			Value:  convErr.value,
+			Index:  convErr.index,
+			Err:    convErr.err,
+		})
+	}
+
+	if target.Kind() != reflect.Slice {
+		return result, true, fieldErrors[0]
+	}
+
+	return result, true, fieldErrors
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
 /*
 IsHtmx returns true if the request came from the Htmx library.
 */
@@ -232,6 +330,43 @@ func StringListFromRequest(r *http.Request, name, seperator string) []string {
 	return value
 }
 
+/*
+unmarshalBody decodes b into dest based on the provided content type.
+Media type parameters, such as charset, are ignored.
+*/
+func unmarshalBody(contentType string, b []byte, dest any) error {
+	if err := decodeBody(contentType, b, dest); err != nil {
+		return fmt.Errorf("error unmarshaling body to destination: %w, contents: %s", err, string(b))
+	}
+
+	return nil
+}
+
+/*
+decodeBody is unmarshalBody without the body in its errors, for messages
+that may be sent back to the client.
+*/
+func decodeBody(contentType string, b []byte, dest any) error {
+	var (
+		err       error
+		mediaType string
+	)
+
+	if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+		mediaType = contentType
+	}
+
+	switch mediaType {
+	case "application/json":
+		return json.Unmarshal(b, dest)
+
+	case "application/xml":
+		return xml.Unmarshal(b, dest)
+	}
+
+	return fmt.Errorf("unsupported content type: %s", contentType)
+}
+
 func getInt(r *http.Request, name string, size int) int64 {
 	var (
 		err    error
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -130,6 +131,138 @@ func TestGetFromRequest(t *testing.T) {
 	})
 }
 
+func TestLookup(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/?page=abc&size=10&ids=1&ids=x&ids=3&ids=y&ratio=0.5", nil)
+		req.SetPathValue("id", "42")
+		req.SetPathValue("bad", "1e")
+		return req
+	}
+
+	t.Run("Found", func(t *testing.T) {
+		got, found, err := Lookup[int](newRequest(), "size")
+
+		if err != nil || !found || got != 10 {
+			t.Errorf("Expected (10, true, nil), got (%d, %v, %v)", got, found, err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		got, found, err := Lookup[int](newRequest(), "nonexistent")
+
+		if err != nil || found || got != 0 {
+			t.Errorf("Expected (0, false, nil), got (%d, %v, %v)", got, found, err)
+		}
+	})
+
+	t.Run("PathValue", func(t *testing.T) {
+		got, found, err := GetE[uint32](newRequest(), "id")
+
+		if err != nil || !found || got != 42 {
+			t.Errorf("Expected (42, true, nil), got (%d, %v, %v)", got, found, err)
+		}
+	})
+
+	t.Run("InvalidFormValue", func(t *testing.T) {
+		_, found, err := Lookup[int64](newRequest(), "page")
+
+		if !found {
+			t.Error("Expected found to be true for an invalid value")
+		}
+
+		var fieldErr *FieldError
+
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("Expected a *FieldError, got %v", err)
+		}
+
+		if fieldErr.Name != "page" || fieldErr.Source != SourceForm || fieldErr.Value != "abc" || fieldErr.Index != -1 {
+			t.Errorf("Unexpected field error contents: %+v", fieldErr)
+		}
+	})
+
+	t.Run("InvalidFormValueFallsBackToPath", func(t *testing.T) {
+		req := newRequest()
+		req.SetPathValue("page", "7")
+
+		got, found, err := Lookup[int](req, "page")
+
+		if err != nil || !found || got != 7 {
+			t.Errorf("Expected (7, true, nil), got (%d, %v, %v)", got, found, err)
+		}
+
+		if Get[int](req, "page") != got {
+			t.Errorf("Expected Get to agree, got %d", Get[int](req, "page"))
+		}
+	})
+
+	t.Run("InvalidPathValue", func(t *testing.T) {
+		_, _, err := Lookup[float64](newRequest(), "bad")
+
+		var fieldErr *FieldError
+
+		if !errors.As(err, &fieldErr) || fieldErr.Source != SourcePath || fieldErr.Value != "1e" {
+			t.Errorf("Expected a path FieldError for '1e', got %v", err)
+		}
+	})
+
+	t.Run("SliceReportsIndexes", func(t *testing.T) {
+		got, found, err := Lookup[[]int](newRequest(), "ids")
+
+		if !found {
+			t.Error("Expected found to be true")
+		}
+
+		if !reflect.DeepEqual(got, []int{1, 3}) {
+			t.Errorf("Expected converted elements [1 3], got %v", got)
+		}
+
+		var fieldErrors FieldErrors
+
+		if !errors.As(err, &fieldErrors) || len(fieldErrors) != 2 {
+			t.Fatalf("Expected two FieldErrors, got %v", err)
+		}
+
+		if fieldErrors[0].Index != 1 || fieldErrors[0].Value != "x" || fieldErrors[1].Index != 3 || fieldErrors[1].Value != "y" {
+			t.Errorf("Unexpected slice errors: %v", fieldErrors)
+		}
+	})
+
+	t.Run("Bool", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/?flag=yes", nil)
+
+		if _, _, err := Lookup[bool](req, "flag"); err == nil {
+			t.Error("Expected an error for an invalid bool, but got nil")
+		}
+	})
+}
+
+func TestGetOr(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?page=abc&size=10&ratio=0.5", nil)
+
+	testCases := []struct {
+		name     string
+		param    string
+		expected int
+	}{
+		{"Found", "size", 10},
+		{"Missing", "nonexistent", 5},
+		{"Invalid", "page", 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GetOr(req, tc.param, 5); got != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+
+	if got := GetOr(req, "ratio", float32(1)); got != 0.5 {
+		t.Errorf("Expected 0.5 for ratio, got %v", got)
+	}
+}
+
 func TestGetStringListFromRequest(t *testing.T) {
 	req := httptest.NewRequest("GET", "/?list=a,b,c", nil)
 	expected := []string{"a", "b", "c"}
@@ -0,0 +1,589 @@
+package requests
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+ValidationError describes a single field that failed a validation rule.
+Field is the path to the field, using JSON names when available, such
+as "items[0].name".
+*/
+type ValidationError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+/*
+ValidationErrors is returned by Validate when one or more fields fail
+validation. It marshals to JSON as a message and a map of field names to
+messages, so it can be handed directly to responses.Json or
+responses.JsonUnprocessableEntity.
+*/
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, validationErr := range e {
+		messages = append(messages, validationErr.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+/*
+FieldMessages groups the validation messages by field.
+*/
+func (e ValidationErrors) FieldMessages() map[string][]string {
+	result := make(map[string][]string)
+
+	for _, validationErr := range e {
+		result[validationErr.Field] = append(result[validationErr.Field], validationErr.Message)
+	}
+
+	return result
+}
+
+func (e ValidationErrors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message string              `json:"message"`
+		Errors  map[string][]string `json:"errors"`
+	}{
+		Message: "validation failed",
+		Errors:  e.FieldMessages(),
+	})
+}
+
+/*
+ValidationContext is handed to a ValidationRule. Value is the field being
+validated, with pointers already dereferenced, and Parent is the struct
+that contains it, which allows rules to compare against sibling fields.
+*/
+type ValidationContext struct {
+	Field  string
+	Value  reflect.Value
+	Param  string
+	Parent reflect.Value
+}
+
+/*
+ValidationRule validates a single field. It returns nil when the value is
+valid, or an error whose message describes the failure, such as
+"must be a valid email address".
+*/
+type ValidationRule func(ctx ValidationContext) error
+
+var (
+	validationMutex = &sync.RWMutex{}
+	validationRules = map[string]ValidationRule{
+		"required": validateRequired,
+		"min":      validateMin,
+		"max":      validateMax,
+		"len":      validateLen,
+		"regex":    validateRegex,
+		"oneof":    validateOneOf,
+		"email":    validateEmail,
+		"url":      validateURL,
+		"eqfield":  compareField("eqfield", "must be equal to", func(c int) bool { return c == 0 }),
+		"nefield":  compareField("nefield", "must not be equal to", func(c int) bool { return c != 0 }),
+		"gtfield":  compareField("gtfield", "must be greater than", func(c int) bool { return c > 0 }),
+		"gtefield": compareField("gtefield", "must be greater than or equal to", func(c int) bool { return c >= 0 }),
+		"ltfield":  compareField("ltfield", "must be less than", func(c int) bool { return c < 0 }),
+		"ltefield": compareField("ltefield", "must be less than or equal to", func(c int) bool { return c <= 0 }),
+	}
+
+	regexCache = &sync.Map{}
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+/*
+RegisterValidation adds a custom rule that can be referenced by name in
+validate tags. Registering a name that already exists replaces the rule.
+*/
+func RegisterValidation(name string, rule ValidationRule) {
+	validationMutex.Lock()
+	defer validationMutex.Unlock()
+
+	validationRules[name] = rule
+}
+
+/*
+Validate checks a struct, or pointer to a struct, against the rules in its
+`validate` struct tags. Rules are separated by commas, and parameters follow
+an equal sign. Use \, to include a literal comma in a parameter.
+
+	type CreateEvent struct {
+		Name  string    `json:"name" validate:"required,max=100"`
+		Email string    `json:"email" validate:"omitempty,email"`
+		Kind  string    `json:"kind" validate:"oneof=public private"`
+		Start time.Time `json:"start" validate:"required"`
+		End   time.Time `json:"end" validate:"required,gtfield=Start"`
+		Tags  []Tag     `json:"tags" validate:"max=5"`
+	}
+
+The built-in rules are required, omitempty, min, max, len, regex, oneof,
+email, url, eqfield, nefield, gtfield, gtefield, ltfield, and ltefield.
+When required fails, the field's other rules are skipped. Nested structs,
+and slices of structs, are validated recursively, and the fields of an
+embedded struct are reported as fields of its parent, as encoding/json
+does.
+
+When one or more fields fail, a ValidationErrors is returned. Any other
+error indicates a problem with the tags themselves, such as an unknown rule.
+*/
+func Validate(v any) error {
+	var (
+		err    error
+		result ValidationErrors
+	)
+
+	value := reflect.ValueOf(v)
+
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate non-struct type %T", v)
+	}
+
+	if result, err = validateStruct(value, ""); err != nil {
+		return err
+	}
+
+	if len(result) > 0 {
+		return result
+	}
+
+	return nil
+}
+
+func validateStruct(value reflect.Value, prefix string) (ValidationErrors, error) {
+	var (
+		err    error
+		result ValidationErrors
+		nested ValidationErrors
+	)
+
+	structType := value.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldPath := joinFieldPath(prefix, fieldDisplayName(field))
+		fieldValue := value.Field(i)
+		tag := field.Tag.Get("validate")
+
+		if tag == "-" {
+			continue
+		}
+
+		skip := false
+
+		for _, rule := range splitRules(tag) {
+			name, param, _ := strings.Cut(rule, "=")
+
+			if name == "omitempty" {
+				if fieldValue.IsZero() {
+					skip = true
+					break
+				}
+
+				continue
+			}
+
+			validationMutex.RLock()
+			validationRule, ok := validationRules[name]
+			validationMutex.RUnlock()
+
+			if !ok {
+				return nil, fmt.Errorf("unknown validation rule %q on field %s", name, field.Name)
+			}
+
+			ctx := ValidationContext{
+				Field:  fieldPath,
+				Value:  indirect(fieldValue),
+				Param:  param,
+				Parent: value,
+			}
+
+			if ctx.Value.Kind() == reflect.Invalid && name != "required" {
+				continue
+			}
+
+			if err = validationRule(ctx); err != nil {
+				if ruleErr, isRuleErr := err.(*ruleDefinitionError); isRuleErr {
+					return nil, fmt.Errorf("invalid validation rule %q on field %s: %w", rule, field.Name, ruleErr.err)
+				}
+
+				result = append(result, ValidationError{
+					Field:   fieldPath,
+					Rule:    name,
+					Param:   param,
+					Message: err.Error(),
+				})
+
+				// A missing value fails every other rule too, which only
+				// adds noise.
+				if name == "required" {
+					skip = true
+					break
+				}
+			}
+		}
+
+		if skip {
+			continue
+		}
+
+		nestedPath := fieldPath
+
+		if isPromotedField(field) {
+			nestedPath = prefix
+		}
+
+		if nested, err = validateNested(indirect(fieldValue), nestedPath); err != nil {
+			return nil, err
+		}
+
+		result = append(result, nested...)
+	}
+
+	return result, nil
+}
+
+func validateNested(value reflect.Value, path string) (ValidationErrors, error) {
+	var (
+		err    error
+		result ValidationErrors
+		nested ValidationErrors
+	)
+
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return nil, nil
+		}
+
+		return validateStruct(value, path)
+
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < value.Len(); index++ {
+			elem := indirect(value.Index(index))
+
+			if elem.Kind() != reflect.Struct || elem.Type() == timeType {
+				continue
+			}
+
+			if nested, err = validateStruct(elem, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return nil, err
+			}
+
+			result = append(result, nested...)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+ruleDefinitionError marks a failure caused by a malformed rule, such as a
+non-numeric min, rather than by the value being validated.
+*/
+type ruleDefinitionError struct {
+	err error
+}
+
+func (e *ruleDefinitionError) Error() string {
+	return e.err.Error()
+}
+
+func validateRequired(ctx ValidationContext) error {
+	if !ctx.Value.IsValid() || ctx.Value.IsZero() {
+		return fmt.Errorf("is required")
+	}
+
+	if length, ok := valueLength(ctx.Value); ok && length == 0 {
+		return fmt.Errorf("is required")
+	}
+
+	return nil
+}
+
+func validateMin(ctx ValidationContext) error {
+	return validateBound(ctx, "at least", func(c int) bool { return c >= 0 })
+}
+
+func validateMax(ctx ValidationContext) error {
+	return validateBound(ctx, "at most", func(c int) bool { return c <= 0 })
+}
+
+func validateLen(ctx ValidationContext) error {
+	return validateBound(ctx, "exactly", func(c int) bool { return c == 0 })
+}
+
+func validateBound(ctx ValidationContext, description string, ok func(c int) bool) error {
+	var (
+		err   error
+		limit float64
+	)
+
+	if limit, err = strconv.ParseFloat(ctx.Param, 64); err != nil {
+		return &ruleDefinitionError{err: fmt.Errorf("parameter %q is not a number", ctx.Param)}
+	}
+
+	if length, isLength := valueLength(ctx.Value); isLength {
+		if ok(cmp.Compare(float64(length), limit)) {
+			return nil
+		}
+
+		if ctx.Value.Kind() == reflect.String {
+			return fmt.Errorf("must be %s %s characters long", description, ctx.Param)
+		}
+
+		return fmt.Errorf("must contain %s %s items", description, ctx.Param)
+	}
+
+	if number, isNumber := valueNumber(ctx.Value); isNumber {
+		if ok(cmp.Compare(number, limit)) {
+			return nil
+		}
+
+		return fmt.Errorf("must be %s %s", description, ctx.Param)
+	}
+
+	return &ruleDefinitionError{err: fmt.Errorf("unsupported type %s", ctx.Value.Type())}
+}
+
+func validateRegex(ctx ValidationContext) error {
+	var (
+		err     error
+		pattern *regexp.Regexp
+	)
+
+	if ctx.Value.Kind() != reflect.String {
+		return &ruleDefinitionError{err: fmt.Errorf("unsupported type %s", ctx.Value.Type())}
+	}
+
+	if cached, ok := regexCache.Load(ctx.Param); ok {
+		pattern = cached.(*regexp.Regexp)
+	} else {
+		if pattern, err = regexp.Compile(ctx.Param); err != nil {
+			return &ruleDefinitionError{err: err}
+		}
+
+		regexCache.Store(ctx.Param, pattern)
+	}
+
+	if !pattern.MatchString(ctx.Value.String()) {
+		return fmt.Errorf("must match the pattern %s", ctx.Param)
+	}
+
+	return nil
+}
+
+func validateOneOf(ctx ValidationContext) error {
+	options := strings.Fields(ctx.Param)
+	value := fmt.Sprint(ctx.Value.Interface())
+
+	for _, option := range options {
+		if value == option {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must be one of: %s", strings.Join(options, ", "))
+}
+
+func validateEmail(ctx ValidationContext) error {
+	if ctx.Value.Kind() != reflect.String {
+		return &ruleDefinitionError{err: fmt.Errorf("unsupported type %s", ctx.Value.Type())}
+	}
+
+	if address, err := mail.ParseAddress(ctx.Value.String()); err != nil || address.Address != ctx.Value.String() {
+		return fmt.Errorf("must be a valid email address")
+	}
+
+	return nil
+}
+
+func validateURL(ctx ValidationContext) error {
+	if ctx.Value.Kind() != reflect.String {
+		return &ruleDefinitionError{err: fmt.Errorf("unsupported type %s", ctx.Value.Type())}
+	}
+
+	if parsed, err := url.ParseRequestURI(ctx.Value.String()); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("must be a valid URL")
+	}
+
+	return nil
+}
+
+func compareField(name, description string, ok func(c int) bool) ValidationRule {
+	return func(ctx ValidationContext) error {
+		other, found := ctx.Parent.Type().FieldByName(ctx.Param)
+
+		if !found {
+			return &ruleDefinitionError{err: fmt.Errorf("%s references unknown field %q", name, ctx.Param)}
+		}
+
+		otherValue := indirect(ctx.Parent.FieldByIndex(other.Index))
+
+		if !otherValue.IsValid() {
+			return nil
+		}
+
+		result, comparable := compareValues(ctx.Value, otherValue)
+
+		if !comparable {
+			return &ruleDefinitionError{err: fmt.Errorf("cannot compare %s with %s", ctx.Value.Type(), otherValue.Type())}
+		}
+
+		if !ok(result) {
+			return fmt.Errorf("%s %s", description, fieldDisplayName(other))
+		}
+
+		return nil
+	}
+}
+
+func compareValues(a, b reflect.Value) (int, bool) {
+	if a.Type() == timeType && b.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), true
+	}
+
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return cmp.Compare(a.String(), b.String()), true
+	}
+
+	aNumber, aOk := valueNumber(a)
+	bNumber, bOk := valueNumber(b)
+
+	if aOk && bOk {
+		return cmp.Compare(aNumber, bNumber), true
+	}
+
+	return 0, false
+}
+
+func valueLength(value reflect.Value) (int, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), true
+
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len(), true
+	}
+
+	return 0, false
+}
+
+func valueNumber(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+
+	return 0, false
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+
+		value = value.Elem()
+	}
+
+	return value
+}
+
+func fieldDisplayName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+
+	return field.Name
+}
+
+/*
+isPromotedField reports whether field is an embedded struct whose fields,
+as with encoding/json, belong to the parent because it has no JSON name
+of its own.
+*/
+func isPromotedField(field reflect.StructField) bool {
+	fieldType := field.Type
+
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+/*
+splitRules splits a validate tag on commas, honoring \, as an escaped comma.
+*/
+func splitRules(tag string) []string {
+	result := []string{}
+	current := strings.Builder{}
+
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			current.WriteByte(',')
+			i++
+
+		case tag[i] == ',':
+			result = append(result, current.String())
+			current.Reset()
+
+		default:
+			current.WriteByte(tag[i])
+		}
+	}
+
+	if current.Len() > 0 {
+		result = append(result, current.String())
+	}
+
+	return result
+}
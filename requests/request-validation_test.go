@@ -0,0 +1,228 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validationTestTag struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type validationTestEvent struct {
+	Name     string              `json:"name" validate:"required,min=3,max=10"`
+	Email    string              `json:"email" validate:"omitempty,email"`
+	Website  string              `json:"website" validate:"omitempty,url"`
+	Kind     string              `json:"kind" validate:"oneof=public private"`
+	Code     string              `json:"code" validate:"regex=^[A-Z]{2}\\,?[0-9]+$"`
+	Seats    int                 `json:"seats" validate:"min=1,max=100"`
+	Ratio    *float64            `json:"ratio" validate:"max=1"`
+	Start    time.Time           `json:"start" validate:"required"`
+	End      time.Time           `json:"end" validate:"required,gtfield=Start"`
+	Tags     []validationTestTag `json:"tags" validate:"max=2"`
+	Location *validationTestTag  `json:"location"`
+	Pin      string              `validate:"len=4"`
+	Ignored  string              `validate:"-"`
+}
+
+func validEvent() validationTestEvent {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	return validationTestEvent{
+		Name:    "Meetup",
+		Email:   "adam@example.com",
+		Website: "https://example.com/events",
+		Kind:    "public",
+		Code:    "AB,123",
+		Seats:   10,
+		Start:   start,
+		End:     start.Add(time.Hour),
+		Tags:    []validationTestTag{{Name: "go"}},
+		Pin:     "1234",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		event := validEvent()
+
+		if err := Validate(&event); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		ratio := 1.5
+		event := validEvent()
+		event.Name = ""
+		event.Email = "not an email"
+		event.Website = "/relative"
+		event.Kind = "secret"
+		event.Code = "abc"
+		event.Seats = 0
+		event.Ratio = &ratio
+		event.End = event.Start.Add(-time.Hour)
+		event.Tags = []validationTestTag{{Name: "ok"}, {Name: ""}, {Name: "toolong"}}
+		event.Location = &validationTestTag{}
+		event.Pin = "12"
+
+		err := Validate(event)
+
+		var validationErrors ValidationErrors
+
+		if !errors.As(err, &validationErrors) {
+			t.Fatalf("Expected ValidationErrors, got %v", err)
+		}
+
+		got := []string{}
+
+		for _, validationErr := range validationErrors {
+			got = append(got, validationErr.Field+":"+validationErr.Rule)
+		}
+
+		expected := []string{
+			"name:required",
+			"email:email",
+			"website:url",
+			"kind:oneof",
+			"code:regex",
+			"seats:min",
+			"ratio:max",
+			"end:gtfield",
+			"tags:max",
+			"tags[1].name:required",
+			"tags[2].name:max",
+			"location.name:required",
+			"Pin:len",
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected failures\n%v\ngot\n%v", expected, got)
+		}
+
+		messages := validationErrors.FieldMessages()
+
+		if !reflect.DeepEqual(messages["end"], []string{"must be greater than start"}) {
+			t.Errorf("Unexpected message for end: %v", messages["end"])
+		}
+
+		if !reflect.DeepEqual(messages["name"], []string{"is required"}) {
+			t.Errorf("Unexpected messages for name: %v", messages["name"])
+		}
+	})
+
+	t.Run("EmbeddedStruct", func(t *testing.T) {
+		type Audit struct {
+			CreatedBy string `json:"createdBy" validate:"required"`
+		}
+
+		type Revision struct {
+			Number int `json:"number" validate:"min=1"`
+		}
+
+		type document struct {
+			Audit
+			*Revision
+			Owner Audit  `json:"owner"`
+			Title string `json:"title" validate:"required,min=3"`
+		}
+
+		err := Validate(document{Revision: &Revision{}})
+
+		var validationErrors ValidationErrors
+
+		if !errors.As(err, &validationErrors) {
+			t.Fatalf("Expected ValidationErrors, got %v", err)
+		}
+
+		got := []string{}
+
+		for _, validationErr := range validationErrors {
+			got = append(got, validationErr.Field+":"+validationErr.Rule)
+		}
+
+		expected := []string{"createdBy:required", "number:min", "owner.createdBy:required", "title:required"}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected failures %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("MarshalJSON", func(t *testing.T) {
+		validationErrors := ValidationErrors{
+			{Field: "name", Rule: "required", Message: "is required"},
+		}
+
+		b, err := json.Marshal(validationErrors)
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+
+		expected := `{"message":"validation failed","errors":{"name":["is required"]}}`
+
+		if string(b) != expected {
+			t.Errorf("Expected %s, got %s", expected, string(b))
+		}
+	})
+
+	t.Run("CustomRule", func(t *testing.T) {
+		RegisterValidation("even", func(ctx ValidationContext) error {
+			if ctx.Value.Int()%2 != 0 {
+				return fmt.Errorf("must be even")
+			}
+
+			return nil
+		})
+
+		type payload struct {
+			Count int `json:"count" validate:"even"`
+		}
+
+		err := Validate(payload{Count: 3})
+
+		if err == nil || err.Error() != "count must be even" {
+			t.Errorf("Expected 'count must be even', got %v", err)
+		}
+
+		if err := Validate(payload{Count: 4}); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("UnknownRule", func(t *testing.T) {
+		type payload struct {
+			Name string `validate:"bogus"`
+		}
+
+		err := Validate(payload{})
+
+		if err == nil || !strings.Contains(err.Error(), "unknown validation rule") {
+			t.Errorf("Expected an unknown rule error, got %v", err)
+		}
+	})
+
+	t.Run("BadParameter", func(t *testing.T) {
+		type payload struct {
+			Name string `validate:"min=abc"`
+		}
+
+		err := Validate(payload{Name: "x"})
+
+		var validationErrors ValidationErrors
+
+		if err == nil || errors.As(err, &validationErrors) {
+			t.Errorf("Expected a rule definition error, got %v", err)
+		}
+	})
+
+	t.Run("NonStruct", func(t *testing.T) {
+		if err := Validate("hello"); err == nil {
+			t.Error("Expected an error validating a non-struct, but got nil")
+		}
+	})
+}
@@ -0,0 +1,460 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Encoder writes a value using a single media type. Register encoders with a
+Negotiator to make them available for content negotiation.
+*/
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, value any) error
+}
+
+/*
+CsvMarshaler is implemented by values that know how to render themselves
+as CSV records, including the header row.
+*/
+type CsvMarshaler interface {
+	CsvRecords() ([][]string, error)
+}
+
+type encoderFunc struct {
+	contentType string
+	encode      func(w io.Writer, value any) error
+}
+
+func (e encoderFunc) ContentType() string {
+	return e.contentType
+}
+
+func (e encoderFunc) Encode(w io.Writer, value any) error {
+	return e.encode(w, value)
+}
+
+/*
+NewEncoder creates an Encoder from a content type and an encoding function.
+*/
+func NewEncoder(contentType string, encode func(w io.Writer, value any) error) Encoder {
+	return encoderFunc{contentType: contentType, encode: encode}
+}
+
+var (
+	JsonEncoder = NewEncoder("application/json", func(w io.Writer, value any) error {
+		return json.NewEncoder(w).Encode(value)
+	})
+
+	XmlEncoder = NewEncoder("application/xml", func(w io.Writer, value any) error {
+		return xml.NewEncoder(w).Encode(value)
+	})
+
+	// HtmlEncoder escapes values so they display as text. Only template.HTML values are written as markup.
+	HtmlEncoder = NewEncoder("text/html", encodeHtml)
+
+	TextEncoder = NewEncoder("text/plain", func(w io.Writer, value any) error {
+		_, err := fmt.Fprintf(w, "%v", value)
+		return err
+	})
+
+	CsvEncoder = NewEncoder("text/csv", encodeCsv)
+
+	DefaultNegotiator = NewNegotiator(JsonEncoder, XmlEncoder, HtmlEncoder, TextEncoder, CsvEncoder)
+)
+
+/*
+MediaRange is a single entry from an Accept header.
+*/
+type MediaRange struct {
+	Type    string
+	Subtype string
+	Params  map[string]string
+	Quality float64
+}
+
+/*
+Matches reports whether the media range accepts contentType. When it does,
+specificity ranks how closely it matched: 0 for the full wildcard, 1 for a
+subtype wildcard, 2 for an exact type, and 3 for an exact type with matching
+parameters.
+*/
+func (m MediaRange) Matches(contentType string) (specificity int, ok bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return 0, false
+	}
+
+	mainType, subtype, _ := strings.Cut(mediaType, "/")
+
+	for key, value := range m.Params {
+		if !strings.EqualFold(params[key], value) {
+			return 0, false
+		}
+	}
+
+	switch {
+	case m.Type == "*" && m.Subtype == "*":
+		return 0, true
+
+	case m.Type == mainType && m.Subtype == "*":
+		return 1, true
+
+	case m.Type == mainType && m.Subtype == subtype:
+		if len(m.Params) > 0 {
+			return 3, true
+		}
+
+		return 2, true
+	}
+
+	return 0, false
+}
+
+/*
+ParseAccept parses an Accept header into media ranges, ordered by quality
+with the most preferred first. Malformed entries are skipped.
+*/
+func ParseAccept(header string) []MediaRange {
+	result := []MediaRange{}
+
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(entry)
+
+		if err != nil {
+			continue
+		}
+
+		mainType, subtype, ok := strings.Cut(mediaType, "/")
+
+		if !ok || (mainType == "*" && subtype != "*") {
+			continue
+		}
+
+		mediaRange := MediaRange{
+			Type:    mainType,
+			Subtype: subtype,
+			Params:  map[string]string{},
+			Quality: 1,
+		}
+
+		for key, value := range params {
+			if key == "q" {
+				if quality, err := strconv.ParseFloat(value, 64); err == nil && quality >= 0 && quality <= 1 {
+					mediaRange.Quality = quality
+				}
+
+				continue
+			}
+
+			mediaRange.Params[key] = value
+		}
+
+		result = append(result, mediaRange)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Quality > result[j].Quality
+	})
+
+	return result
+}
+
+/*
+Negotiator picks an Encoder for a request based on its Accept header.
+Encoders registered first are preferred when the client has no preference.
+*/
+type Negotiator struct {
+	mutex    *sync.RWMutex
+	encoders []Encoder
+}
+
+/*
+NewNegotiator creates a Negotiator with the provided encoders.
+*/
+func NewNegotiator(encoders ...Encoder) *Negotiator {
+	result := &Negotiator{
+		mutex:    &sync.RWMutex{},
+		encoders: []Encoder{},
+	}
+
+	for _, encoder := range encoders {
+		result.Register(encoder)
+	}
+
+	return result
+}
+
+/*
+Register adds an encoder. An encoder for the same content type that was
+already registered is replaced, keeping its position.
+*/
+func (n *Negotiator) Register(encoder Encoder) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for index, existing := range n.encoders {
+		if existing.ContentType() == encoder.ContentType() {
+			n.encoders[index] = encoder
+			return
+		}
+	}
+
+	n.encoders = append(n.encoders, encoder)
+}
+
+/*
+ContentTypes returns the content types of the registered encoders.
+*/
+func (n *Negotiator) ContentTypes() []string {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	result := make([]string, 0, len(n.encoders))
+
+	for _, encoder := range n.encoders {
+		result = append(result, encoder.ContentType())
+	}
+
+	return result
+}
+
+/*
+Select returns the best encoder for the request. For each encoder, the
+most specific matching media range in the Accept header decides its
+quality. The highest quality wins, and ties go to the encoder registered
+first. A missing Accept header accepts anything. The second return value
+is false when no encoder is acceptable.
+*/
+func (n *Negotiator) Select(r *http.Request) (Encoder, bool) {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	if len(n.encoders) == 0 {
+		return nil, false
+	}
+
+	header := strings.Join(r.Header.Values("Accept"), ",")
+
+	if strings.TrimSpace(header) == "" {
+		return n.encoders[0], true
+	}
+
+	ranges := ParseAccept(header)
+
+	var (
+		best        Encoder
+		bestQuality float64
+	)
+
+	for _, encoder := range n.encoders {
+		quality := -1.0
+		specificity := -1
+
+		for _, mediaRange := range ranges {
+			if s, ok := mediaRange.Matches(encoder.ContentType()); ok && s > specificity {
+				specificity = s
+				quality = mediaRange.Quality
+			}
+		}
+
+		if quality > bestQuality {
+			best = encoder
+			bestQuality = quality
+		}
+	}
+
+	return best, best != nil
+}
+
+/*
+Write encodes value with the best encoder for the request and writes it
+with the provided status. The Vary header always includes Accept. When no
+encoder is acceptable, a 406 Not Acceptable is written listing the supported
+media types. If encoding fails, a 500 is written instead.
+*/
+func (n *Negotiator) Write(w http.ResponseWriter, r *http.Request, status int, value any) {
+	var (
+		err error
+	)
+
+	addVary(w.Header(), "Accept")
+
+	encoder, ok := n.Select(r)
+
+	if !ok {
+		supported := strings.Join(n.ContentTypes(), ", ")
+		Text(w, http.StatusNotAcceptable, "Not Acceptable. Supported media types: "+supported)
+		return
+	}
+
+	b := &bytes.Buffer{}
+
+	if err = encoder.Encode(b, value); err != nil {
+		Text(w, http.StatusInternalServerError, "Error encoding value for writing")
+		return
+	}
+
+	Bytes(w, status, encoder.ContentType(), b.Bytes())
+}
+
+/*
+Negotiate writes value using the DefaultNegotiator, which supports JSON,
+XML, HTML, plain text, and CSV, preferring JSON when the client accepts
+anything.
+*/
+func Negotiate(w http.ResponseWriter, r *http.Request, status int, value any) {
+	DefaultNegotiator.Write(w, r, status, value)
+}
+
+/*
+NegotiateOK is a convenience wrapper to negotiate a 200 response.
+*/
+func NegotiateOK(w http.ResponseWriter, r *http.Request, value any) {
+	Negotiate(w, r, http.StatusOK, value)
+}
+
+func addVary(header http.Header, value string) {
+	for _, existing := range header.Values("Vary") {
+		for _, field := range strings.Split(existing, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", value)
+}
+
+/*
+encodeHtml escapes the value, so that strings from users cannot inject
+markup into the page. Values that are already HTML must be passed as
+template.HTML, which is trusted and written as it is.
+*/
+func encodeHtml(w io.Writer, value any) error {
+	if trusted, ok := value.(template.HTML); ok {
+		_, err := io.WriteString(w, string(trusted))
+		return err
+	}
+
+	_, err := io.WriteString(w, html.EscapeString(fmt.Sprintf("%v", value)))
+	return err
+}
+
+/*
+encodeCsv writes [][]string, CsvMarshaler values, and slices of structs.
+Struct columns use the `csv` tag when present, otherwise the field name,
+and a tag of "-" skips the field.
+*/
+func encodeCsv(w io.Writer, value any) error {
+	var (
+		err     error
+		records [][]string
+	)
+
+	switch v := value.(type) {
+	case [][]string:
+		records = v
+
+	case CsvMarshaler:
+		if records, err = v.CsvRecords(); err != nil {
+			return err
+		}
+
+	default:
+		if records, err = structCsvRecords(value); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+
+	if err = writer.WriteAll(records); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+func structCsvRecords(value any) ([][]string, error) {
+	v := reflect.ValueOf(value)
+
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("cannot encode %T as CSV", value)
+	}
+
+	elemType := v.Type().Elem()
+
+	for elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot encode %T as CSV", value)
+	}
+
+	header := []string{}
+	fieldIndexes := []int{}
+
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		name := field.Tag.Get("csv")
+
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		header = append(header, name)
+		fieldIndexes = append(fieldIndexes, i)
+	}
+
+	records := [][]string{header}
+
+	for index := 0; index < v.Len(); index++ {
+		elem := v.Index(index)
+
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+
+		record := make([]string, 0, len(fieldIndexes))
+
+		for _, fieldIndex := range fieldIndexes {
+			if !elem.IsValid() {
+				record = append(record, "")
+				continue
+			}
+
+			record = append(record, fmt.Sprintf("%v", elem.Field(fieldIndex).Interface()))
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
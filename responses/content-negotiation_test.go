@@ -0,0 +1,201 @@
+package responses
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type negotiationTestRow struct {
+	Name   string `csv:"name" xml:"name"`
+	Age    int    `csv:"age" xml:"age"`
+	Secret string `csv:"-" xml:"-"`
+}
+
+func TestParseAccept(t *testing.T) {
+	got := ParseAccept("text/html;level=1, text/*;q=0.3, application/json;q=0.9, */*;q=0.1, bad/entry;q=x, *;q=0.5")
+
+	expected := []MediaRange{
+		{Type: "text", Subtype: "html", Params: map[string]string{"level": "1"}, Quality: 1},
+		{Type: "bad", Subtype: "entry", Params: map[string]string{}, Quality: 1},
+		{Type: "application", Subtype: "json", Params: map[string]string{}, Quality: 0.9},
+		{Type: "text", Subtype: "*", Params: map[string]string{}, Quality: 0.3},
+		{Type: "*", Subtype: "*", Params: map[string]string{}, Quality: 0.1},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestNegotiatorSelect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		accept   string
+		expected string
+		ok       bool
+	}{
+		{"NoAcceptHeader", "", "application/json", true},
+		{"Wildcard", "*/*", "application/json", true},
+		{"Exact", "application/xml", "application/xml", true},
+		{"Browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html", true},
+		{"QualityWins", "application/json;q=0.5, text/csv", "text/csv", true},
+		{"TypeWildcard", "text/*", "text/html", true},
+		{"SpecificRangeOverridesWildcard", "text/*;q=0.9, text/html;q=0.1", "text/plain", true},
+		{"ExcludedWithZeroQuality", "application/json;q=0, */*;q=0.5", "application/xml", true},
+		{"ParametersMustMatch", "text/html;level=1", "", false},
+		{"NothingAcceptable", "image/png", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			encoder, ok := DefaultNegotiator.Select(req)
+
+			if ok != tc.ok {
+				t.Fatalf("Expected ok to be %v, got %v", tc.ok, ok)
+			}
+
+			if ok && encoder.ContentType() != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, encoder.ContentType())
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	rows := []negotiationTestRow{{Name: "Adam", Age: 30, Secret: "x"}, {Name: "Bob", Age: 40}}
+
+	testCases := []struct {
+		accept       string
+		expectedType string
+		expectedBody string
+	}{
+		{"application/json", "application/json", `[{"Name":"Adam","Age":30,"Secret":"x"},{"Name":"Bob","Age":40,"Secret":""}]` + "\n"},
+		{"text/csv", "text/csv", "name,age\nAdam,30\nBob,40\n"},
+		{"text/plain", "text/plain", "[{Adam 30 x} {Bob 40 }]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expectedType, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tc.accept)
+
+			Negotiate(w, req, http.StatusCreated, rows)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != tc.expectedType {
+				t.Errorf("Expected Content-Type '%s', got '%s'", tc.expectedType, contentType)
+			}
+
+			if vary := w.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Expected Vary 'Accept', got '%s'", vary)
+			}
+
+			if w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "image/png")
+
+		NegotiateOK(w, req, rows)
+
+		if w.Code != http.StatusNotAcceptable {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotAcceptable, w.Code)
+		}
+
+		if !strings.Contains(w.Body.String(), "application/json, application/xml, text/html, text/plain, text/csv") {
+			t.Errorf("Expected the supported list in the body, got: %s", w.Body.String())
+		}
+	})
+
+	t.Run("EncodingError", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "text/csv")
+
+		NegotiateOK(w, req, 42)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+
+	t.Run("HtmlIsEscaped", func(t *testing.T) {
+		testCases := []struct {
+			value        any
+			expectedBody string
+		}{
+			{negotiationTestRow{Name: "<script>alert(1)</script>"}, "{&lt;script&gt;alert(1)&lt;/script&gt; 0 }"},
+			{template.HTML("<p>Trusted</p>"), "<p>Trusted</p>"},
+		}
+
+		for _, tc := range testCases {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", "text/html")
+
+			NegotiateOK(w, req, tc.value)
+
+			if w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, w.Body.String())
+			}
+		}
+	})
+}
+
+func TestNegotiatorCustomEncoder(t *testing.T) {
+	yaml := NewEncoder("application/yaml", func(w io.Writer, value any) error {
+		_, err := fmt.Fprintf(w, "value: %v\n", value)
+		return err
+	})
+
+	negotiator := NewNegotiator(JsonEncoder, yaml)
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Origin, accept")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/yaml, application/json;q=0.8")
+
+	negotiator.Write(w, req, http.StatusOK, 42)
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/yaml" {
+		t.Errorf("Expected Content-Type 'application/yaml', got '%s'", contentType)
+	}
+
+	if w.Body.String() != "value: 42\n" {
+		t.Errorf("Unexpected body: %q", w.Body.String())
+	}
+
+	if vary := w.Header().Values("Vary"); !reflect.DeepEqual(vary, []string{"Origin, accept"}) {
+		t.Errorf("Expected Vary to be left alone, got %v", vary)
+	}
+
+	negotiator.Register(NewEncoder("application/yaml", func(w io.Writer, value any) error {
+		_, err := io.WriteString(w, "replaced")
+		return err
+	}))
+
+	if got := negotiator.ContentTypes(); !reflect.DeepEqual(got, []string{"application/json", "application/yaml"}) {
+		t.Errorf("Expected the replacement to keep its position, got %v", got)
+	}
+}
@@ -0,0 +1,417 @@
+package responses
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+const (
+	ProblemJsonContentType = "application/problem+json"
+	ProblemXmlContentType  = "application/problem+xml"
+	ProblemXmlNamespace    = "urn:ietf:rfc:7807"
+)
+
+/*
+Problem is an RFC 9457 problem details object. Extensions holds any
+additional members, which are written alongside the standard members.
+*/
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+/*
+ProblemFieldError is a single entry in the "errors" extension of a
+validation problem.
+*/
+type ProblemFieldError struct {
+	Field  string `json:"field" xml:"field"`
+	Detail string `json:"detail" xml:"detail"`
+}
+
+/*
+FieldMessager is implemented by errors that carry per-field messages, such
+as requests.ValidationErrors and requests.FieldErrors.
+*/
+type FieldMessager interface {
+	FieldMessages() map[string][]string
+}
+
+var problemMembers = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+}
+
+/*
+NewProblem creates a Problem for the provided status code. The type is
+"about:blank" and the title is the standard text for the status code.
+*/
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+/*
+ValidationProblem creates a 422 Problem whose "errors" extension lists
+every field message in errs.
+*/
+func ValidationProblem(errs FieldMessager) Problem {
+	return NewProblem(http.StatusUnprocessableEntity, "The request failed validation.").WithFieldErrors(errs)
+}
+
+/*
+With returns a copy of the problem with an extension member set. Standard
+member names are ignored.
+*/
+func (p Problem) With(key string, value any) Problem {
+	if problemMembers[key] {
+		return p
+	}
+
+	extensions := make(map[string]any, len(p.Extensions)+1)
+
+	for k, v := range p.Extensions {
+		extensions[k] = v
+	}
+
+	extensions[key] = value
+	p.Extensions = extensions
+
+	return p
+}
+
+/*
+WithFieldErrors returns a copy of the problem with an "errors" extension
+built from errs. Entries are ordered by field name.
+*/
+func (p Problem) WithFieldErrors(errs FieldMessager) Problem {
+	messages := errs.FieldMessages()
+	fields := make([]string, 0, len(messages))
+	result := []ProblemFieldError{}
+
+	for field := range messages {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		for _, message := range messages[field] {
+			result = append(result, ProblemFieldError{Field: field, Detail: message})
+		}
+	}
+
+	return p.With("errors", result)
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	result := make(map[string]any, len(p.Extensions)+5)
+
+	for key, value := range p.Extensions {
+		if !problemMembers[key] {
+			result[key] = value
+		}
+	}
+
+	if p.Type != "" {
+		result["type"] = p.Type
+	}
+
+	if p.Title != "" {
+		result["title"] = p.Title
+	}
+
+	if p.Status != 0 {
+		result["status"] = p.Status
+	}
+
+	if p.Detail != "" {
+		result["detail"] = p.Detail
+	}
+
+	if p.Instance != "" {
+		result["instance"] = p.Instance
+	}
+
+	return json.Marshal(result)
+}
+
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	var (
+		err     error
+		members map[string]json.RawMessage
+	)
+
+	if err = json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+
+	standard := map[string]any{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+
+	for key, raw := range members {
+		if dest, ok := standard[key]; ok {
+			// Members with the wrong type are ignored, as RFC 9457 requires
+			_ = json.Unmarshal(raw, dest)
+			continue
+		}
+
+		var value any
+
+		if err = json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]any)
+		}
+
+		p.Extensions[key] = value
+	}
+
+	return nil
+}
+
+/*
+MarshalXML writes the problem using the XML format from RFC 9457 Appendix B.
+Arrays are written as repeated <i> elements, and maps as child elements.
+*/
+func (p Problem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var (
+		err error
+	)
+
+	start = xml.StartElement{
+		Name: xml.Name{Local: "problem"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: ProblemXmlNamespace}},
+	}
+
+	if err = e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	standard := []struct {
+		name  string
+		value any
+		empty bool
+	}{
+		{"type", p.Type, p.Type == ""},
+		{"title", p.Title, p.Title == ""},
+		{"status", p.Status, p.Status == 0},
+		{"detail", p.Detail, p.Detail == ""},
+		{"instance", p.Instance, p.Instance == ""},
+	}
+
+	for _, member := range standard {
+		if member.empty {
+			continue
+		}
+
+		if err = e.EncodeElement(member.value, xml.StartElement{Name: xml.Name{Local: member.name}}); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(p.Extensions))
+
+	for key := range p.Extensions {
+		if !problemMembers[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err = encodeProblemXmlValue(e, key, p.Extensions[key]); err != nil {
+			return err
+		}
+	}
+
+	if err = e.EncodeToken(start.End()); err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+func encodeProblemXmlValue(e *xml.Encoder, name string, value any) error {
+	var (
+		err error
+	)
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.EncodeElement(value, start)
+		}
+
+		if err = e.EncodeToken(start); err != nil {
+			return err
+		}
+
+		for index := 0; index < v.Len(); index++ {
+			if err = encodeProblemXmlValue(e, "i", v.Index(index).Interface()); err != nil {
+				return err
+			}
+		}
+
+		return e.EncodeToken(start.End())
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s for problem extension %s", v.Type().Key(), name)
+		}
+
+		if err = e.EncodeToken(start); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, v.Len())
+
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err = encodeProblemXmlValue(e, key, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())).Interface()); err != nil {
+				return err
+			}
+		}
+
+		return e.EncodeToken(start.End())
+	}
+
+	return e.EncodeElement(value, start)
+}
+
+/*
+ProblemJson writes a problem to the response writer as application/problem+json,
+using the problem's status code. A problem without a status is sent as a 500.
+*/
+func ProblemJson(w http.ResponseWriter, problem Problem) {
+	var (
+		err error
+		b   []byte
+	)
+
+	if b, err = json.Marshal(problem); err != nil {
+		problem = NewProblem(http.StatusInternalServerError, "Error marshaling problem details for writing")
+		b, _ = json.Marshal(problem)
+	}
+
+	writeProblem(w, ProblemJsonContentType, problem.Status, b)
+}
+
+/*
+ProblemXml writes a problem to the response writer as application/problem+xml,
+using the problem's status code. A problem without a status is sent as a 500.
+*/
+func ProblemXml(w http.ResponseWriter, problem Problem) {
+	var (
+		err error
+		b   []byte
+	)
+
+	if b, err = xml.Marshal(problem); err != nil {
+		problem = NewProblem(http.StatusInternalServerError, "Error marshaling problem details for writing")
+		b, _ = xml.Marshal(problem)
+	}
+
+	writeProblem(w, ProblemXmlContentType, problem.Status, append([]byte(xml.Header), b...))
+}
+
+/*
+ProblemBadRequest is a convenience wrapper to send a 400 problem.
+*/
+func ProblemBadRequest(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusBadRequest, detail))
+}
+
+/*
+ProblemUnauthorized is a convenience wrapper to send a 401 problem.
+*/
+func ProblemUnauthorized(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusUnauthorized, detail))
+}
+
+/*
+ProblemForbidden is a convenience wrapper to send a 403 problem.
+*/
+func ProblemForbidden(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusForbidden, detail))
+}
+
+/*
+ProblemNotFound is a convenience wrapper to send a 404 problem.
+*/
+func ProblemNotFound(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusNotFound, detail))
+}
+
+/*
+ProblemConflict is a convenience wrapper to send a 409 problem.
+*/
+func ProblemConflict(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusConflict, detail))
+}
+
+/*
+ProblemTooManyRequests is a convenience wrapper to send a 429 problem.
+*/
+func ProblemTooManyRequests(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusTooManyRequests, detail))
+}
+
+/*
+ProblemInternalServerError is a convenience wrapper to send a 500 problem.
+*/
+func ProblemInternalServerError(w http.ResponseWriter, detail string) {
+	ProblemJson(w, NewProblem(http.StatusInternalServerError, detail))
+}
+
+/*
+ProblemValidation is a convenience wrapper to send a 422 problem with an
+"errors" extension listing every field message in errs.
+*/
+func ProblemValidation(w http.ResponseWriter, errs FieldMessager) {
+	ProblemJson(w, ValidationProblem(errs))
+}
+
+func writeProblem(w http.ResponseWriter, contentType string, status int, b []byte) {
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+
+	_, _ = w.Write(b)
+}
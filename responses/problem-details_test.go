@@ -0,0 +1,247 @@
+package responses
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adampresley/httphelpers/requests"
+)
+
+type problemTestFieldErrors map[string][]string
+
+func (e problemTestFieldErrors) FieldMessages() map[string][]string {
+	return e
+}
+
+func TestNewProblem(t *testing.T) {
+	problem := NewProblem(http.StatusNotFound, "Order 42 does not exist")
+
+	expected := Problem{
+		Type:   "about:blank",
+		Title:  "Not Found",
+		Status: http.StatusNotFound,
+		Detail: "Order 42 does not exist",
+	}
+
+	if !reflect.DeepEqual(problem, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, problem)
+	}
+}
+
+func TestProblemJson(t *testing.T) {
+	t.Run("StandardAndExtensionMembers", func(t *testing.T) {
+		var body map[string]any
+		w := httptest.NewRecorder()
+
+		problem := NewProblem(http.StatusForbidden, "Your balance is 30, but that costs 50.").
+			With("balance", 30).
+			With("status", 999)
+		problem.Type = "https://example.com/probs/out-of-credit"
+		problem.Instance = "/account/12345/msgs/abc"
+
+		ProblemJson(w, problem)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != ProblemJsonContentType {
+			t.Errorf("Expected Content-Type '%s', got '%s'", ProblemJsonContentType, contentType)
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to unmarshal response body: %v", err)
+		}
+
+		expected := map[string]any{
+			"type":     "https://example.com/probs/out-of-credit",
+			"title":    "Forbidden",
+			"status":   float64(403),
+			"detail":   "Your balance is 30, but that costs 50.",
+			"instance": "/account/12345/msgs/abc",
+			"balance":  float64(30),
+		}
+
+		if !reflect.DeepEqual(body, expected) {
+			t.Errorf("Expected body %v, got %v", expected, body)
+		}
+	})
+
+	t.Run("MissingStatus", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		ProblemJson(w, Problem{Title: "Something happened"})
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+
+	t.Run("MarshalingError", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		ProblemJson(w, NewProblem(http.StatusBadRequest, "bad").With("channel", make(chan int)))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+
+		if !strings.Contains(w.Body.String(), "Error marshaling problem details") {
+			t.Errorf("Expected error detail in body, got: %s", w.Body.String())
+		}
+	})
+}
+
+func TestProblemUnmarshalJSON(t *testing.T) {
+	var problem Problem
+
+	input := `{"type":"about:blank","title":"Conflict","status":409,"detail":"taken","retryable":false,"instance":7}`
+
+	if err := json.Unmarshal([]byte(input), &problem); err != nil {
+		t.Fatalf("Failed to unmarshal problem: %v", err)
+	}
+
+	expected := Problem{
+		Type:       "about:blank",
+		Title:      "Conflict",
+		Status:     409,
+		Detail:     "taken",
+		Extensions: map[string]any{"retryable": false},
+	}
+
+	if !reflect.DeepEqual(problem, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, problem)
+	}
+}
+
+func TestProblemXml(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	problem := NewProblem(http.StatusUnprocessableEntity, "Invalid input").
+		With("codes", []string{"a", "b"}).
+		With("limits", map[string]int{"max": 5})
+
+	ProblemXml(w, problem)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != ProblemXmlContentType {
+		t.Errorf("Expected Content-Type '%s', got '%s'", ProblemXmlContentType, contentType)
+	}
+
+	expected := xml.Header +
+		`<problem xmlns="urn:ietf:rfc:7807">` +
+		`<type>about:blank</type><title>Unprocessable Entity</title><status>422</status><detail>Invalid input</detail>` +
+		`<codes><i>a</i><i>b</i></codes><limits><max>5</max></limits>` +
+		`</problem>`
+
+	if w.Body.String() != expected {
+		t.Errorf("Expected body\n%s\ngot\n%s", expected, w.Body.String())
+	}
+}
+
+func TestProblemConvenience(t *testing.T) {
+	testCases := []struct {
+		name           string
+		handlerFunc    func(http.ResponseWriter, string)
+		expectedStatus int
+	}{
+		{"ProblemBadRequest", ProblemBadRequest, http.StatusBadRequest},
+		{"ProblemUnauthorized", ProblemUnauthorized, http.StatusUnauthorized},
+		{"ProblemForbidden", ProblemForbidden, http.StatusForbidden},
+		{"ProblemNotFound", ProblemNotFound, http.StatusNotFound},
+		{"ProblemConflict", ProblemConflict, http.StatusConflict},
+		{"ProblemTooManyRequests", ProblemTooManyRequests, http.StatusTooManyRequests},
+		{"ProblemInternalServerError", ProblemInternalServerError, http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var problem Problem
+			w := httptest.NewRecorder()
+
+			tc.handlerFunc(w, "some detail")
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("Failed to unmarshal problem: %v", err)
+			}
+
+			if problem.Status != tc.expectedStatus || problem.Title != http.StatusText(tc.expectedStatus) || problem.Detail != "some detail" {
+				t.Errorf("Unexpected problem: %+v", problem)
+			}
+		})
+	}
+}
+
+func TestProblemValidation(t *testing.T) {
+	var body struct {
+		Status int                 `json:"status"`
+		Errors []ProblemFieldError `json:"errors"`
+	}
+
+	w := httptest.NewRecorder()
+
+	ProblemValidation(w, problemTestFieldErrors{
+		"name":  {"is required"},
+		"email": {"must be a valid email address"},
+	})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	expected := []ProblemFieldError{
+		{Field: "email", Detail: "must be a valid email address"},
+		{Field: "name", Detail: "is required"},
+	}
+
+	if !reflect.DeepEqual(body.Errors, expected) {
+		t.Errorf("Expected errors %v, got %v", expected, body.Errors)
+	}
+}
+
+func TestProblemValidationHidesRequestBody(t *testing.T) {
+	type bindRequest struct {
+		Payload struct {
+			Age int `json:"age"`
+		} `body:""`
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"age": "secret-password-1234"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err := requests.Bind[bindRequest](req)
+
+	var fieldErrors requests.FieldErrors
+
+	if !errors.As(err, &fieldErrors) {
+		t.Fatalf("Expected FieldErrors, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	ProblemValidation(w, fieldErrors)
+
+	if strings.Contains(w.Body.String(), "secret-password-1234") {
+		t.Errorf("Expected the request body to be left out, got %s", w.Body.String())
+	}
+
+	if !strings.Contains(w.Body.String(), "invalid request body") {
+		t.Errorf("Expected a body error, got %s", w.Body.String())
+	}
+}
@@ -103,6 +103,14 @@ func JsonUnauthorized(w http.ResponseWriter, value any) {
 	Json(w, http.StatusUnauthorized, value)
 }
 
+/*
+JsonUnprocessableEntity is a convenience wrapper to send a 422 with an
+arbitrary structure. It pairs well with requests.ValidationErrors.
+*/
+func JsonUnprocessableEntity(w http.ResponseWriter, value any) {
+	Json(w, http.StatusUnprocessableEntity, value)
+}
+
 /*
 Text writes content to the response writer with a text/plain header.
 */
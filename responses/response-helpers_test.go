@@ -133,6 +133,7 @@ func TestJsonConvenience(t *testing.T) {
 		{"JsonBadRequest", JsonBadRequest, http.StatusBadRequest},
 		{"JsonInternalServerError", JsonInternalServerError, http.StatusInternalServerError},
 		{"JsonUnauthorized", JsonUnauthorized, http.StatusUnauthorized},
+		{"JsonUnprocessableEntity", JsonUnprocessableEntity, http.StatusUnprocessableEntity},
 	}
 
 	for _, tc := range testCases {
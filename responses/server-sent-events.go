@@ -0,0 +1,302 @@
+package responses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ErrSSEClosed is returned when writing to a stream that has stopped, either
+because the request context was cancelled, Close was called, or a write to
+the client failed.
+*/
+var ErrSSEClosed = errors.New("server-sent events stream is closed")
+
+var sseLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+/*
+SSEEvent is a single server-sent event. Data may span multiple lines.
+A zero Retry is not sent.
+*/
+type SSEEvent struct {
+	ID    string
+	Event string
+	Retry time.Duration
+	Data  string
+}
+
+type SSEOptions struct {
+	HeartbeatInterval time.Duration
+	Retry             time.Duration
+}
+
+type SSEOption func(o *SSEOptions)
+
+/*
+SSE is a text/event-stream response. Create one with NewSSE, and call
+Close when the handler is done with it.
+*/
+type SSE struct {
+	mutex       *sync.Mutex
+	w           http.ResponseWriter
+	controller  *http.ResponseController
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+	lastEventID string
+	closed      bool
+}
+
+/*
+NewSSE prepares the response for server-sent events. It writes the headers
+and a 200 status. When the response writer cannot be flushed it fails with
+http.ErrNotSupported before writing anything, so the caller can still
+respond with an error. The stream stops when the request context is
+cancelled, such as when the client disconnects. By default a heartbeat
+comment is sent every 15 seconds to keep proxies from closing an idle
+connection.
+*/
+func NewSSE(w http.ResponseWriter, r *http.Request, options ...SSEOption) (*SSE, error) {
+	var (
+		err error
+	)
+
+	opts := &SSEOptions{
+		HeartbeatInterval: 15 * time.Second,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if !canFlush(w) {
+		return nil, fmt.Errorf("error flushing server-sent events stream: %w", http.ErrNotSupported)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+
+	result := &SSE{
+		mutex:       &sync.Mutex{},
+		w:           w,
+		controller:  http.NewResponseController(w),
+		ctx:         ctx,
+		cancel:      cancel,
+		stopped:     make(chan struct{}),
+		lastEventID: r.Header.Get("Last-Event-ID"),
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if opts.Retry > 0 {
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", opts.Retry.Milliseconds())
+	}
+
+	if err = result.controller.Flush(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("error flushing server-sent events stream: %w", err)
+	}
+
+	go result.run(opts.HeartbeatInterval)
+
+	return result, nil
+}
+
+/*
+canFlush reports whether w, or a writer it wraps, can be flushed. It
+follows Unwrap the same way http.ResponseController does.
+*/
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+
+		default:
+			return false
+		}
+	}
+}
+
+/*
+WithHeartbeat sets how often a heartbeat comment is sent. Zero disables
+heartbeats.
+*/
+func WithHeartbeat(interval time.Duration) SSEOption {
+	return func(o *SSEOptions) {
+		o.HeartbeatInterval = interval
+	}
+}
+
+/*
+WithRetry sends a retry field when the stream opens, telling the client how
+long to wait before reconnecting.
+*/
+func WithRetry(retry time.Duration) SSEOption {
+	return func(o *SSEOptions) {
+		o.Retry = retry
+	}
+}
+
+/*
+LastEventID returns the Last-Event-ID header sent by a reconnecting client,
+so the handler can resume from where the client left off.
+*/
+func (s *SSE) LastEventID() string {
+	return s.lastEventID
+}
+
+/*
+Done is closed when the stream stops, either because the request context
+was cancelled or Close was called.
+*/
+func (s *SSE) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+/*
+Send writes an event and flushes it to the client. Line breaks in Data
+are split into multiple data lines, and line breaks are removed from ID
+and Event so they cannot inject additional fields.
+*/
+func (s *SSE) Send(event SSEEvent) error {
+	message := strings.Builder{}
+
+	if event.ID != "" {
+		message.WriteString("id: " + sseField(strings.ReplaceAll(event.ID, "\x00", "")) + "\n")
+	}
+
+	if event.Event != "" {
+		message.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+
+	if event.Retry > 0 {
+		message.WriteString(fmt.Sprintf("retry: %d\n", event.Retry.Milliseconds()))
+	}
+
+	if event.Data != "" || event.Event != "" {
+		for _, line := range strings.Split(sseLineBreaks.Replace(event.Data), "\n") {
+			message.WriteString("data: " + line + "\n")
+		}
+	}
+
+	message.WriteString("\n")
+
+	return s.write(message.String())
+}
+
+/*
+SendJson marshals value to JSON and sends it as the data of an event with
+the provided name. An empty name sends an unnamed message event.
+*/
+func (s *SSE) SendJson(event string, value any) error {
+	var (
+		err error
+		b   []byte
+	)
+
+	if b, err = json.Marshal(value); err != nil {
+		return fmt.Errorf("error marshaling server-sent event data: %w", err)
+	}
+
+	return s.Send(SSEEvent{Event: event, Data: string(b)})
+}
+
+/*
+Comment writes a comment line, which clients ignore.
+*/
+func (s *SSE) Comment(text string) error {
+	message := strings.Builder{}
+
+	for _, line := range strings.Split(sseLineBreaks.Replace(text), "\n") {
+		message.WriteString(": " + line + "\n")
+	}
+
+	message.WriteString("\n")
+
+	return s.write(message.String())
+}
+
+/*
+Close stops the stream and its heartbeat. Nothing is written to the
+response after Close returns, so it is safe to call before the handler
+returns. Calling Close more than once has no effect.
+*/
+func (s *SSE) Close() {
+	s.cancel()
+	<-s.stopped
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+}
+
+func (s *SSE) run(heartbeatInterval time.Duration) {
+	var (
+		heartbeat <-chan time.Time
+	)
+
+	defer close(s.stopped)
+
+	if heartbeatInterval > 0 {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.mutex.Lock()
+			s.closed = true
+			s.mutex.Unlock()
+			return
+
+		case <-heartbeat:
+			_ = s.write(": heartbeat\n\n")
+		}
+	}
+}
+
+func (s *SSE) write(message string) error {
+	var (
+		err error
+	)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed || s.ctx.Err() != nil {
+		s.closed = true
+		return ErrSSEClosed
+	}
+
+	if _, err = fmt.Fprint(s.w, message); err == nil {
+		err = s.controller.Flush()
+	}
+
+	if err != nil {
+		s.closed = true
+		s.cancel()
+		return fmt.Errorf("error writing server-sent event: %w", err)
+	}
+
+	return nil
+}
+
+func sseField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,237 @@
+package responses
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+sseTestWriter is a flushable response writer that is safe to read while
+the stream's heartbeat goroutine is writing to it.
+*/
+type sseTestWriter struct {
+	mutex   sync.Mutex
+	header  http.Header
+	body    strings.Builder
+	status  int
+	flushes int
+}
+
+func (w *sseTestWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *sseTestWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.body.Write(b)
+}
+
+func (w *sseTestWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *sseTestWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.flushes++
+}
+
+func (w *sseTestWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.body.String()
+}
+
+type sseNoFlushWriter struct {
+	http.ResponseWriter
+}
+
+type sseUnwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w sseUnwrapWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestNewSSE(t *testing.T) {
+	t.Run("Headers", func(t *testing.T) {
+		w := &sseTestWriter{header: http.Header{}}
+		req := httptest.NewRequest("GET", "/events", nil)
+		req.Header.Set("Last-Event-ID", "41")
+
+		stream, err := NewSSE(w, req, WithHeartbeat(0), WithRetry(3*time.Second))
+		if err != nil {
+			t.Fatalf("NewSSE failed: %v", err)
+		}
+
+		defer stream.Close()
+
+		if w.status != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.status)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+			t.Errorf("Expected Content-Type 'text/event-stream', got '%s'", contentType)
+		}
+
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "no-cache" {
+			t.Errorf("Expected Cache-Control 'no-cache', got '%s'", cacheControl)
+		}
+
+		if stream.LastEventID() != "41" {
+			t.Errorf("Expected last event ID '41', got '%s'", stream.LastEventID())
+		}
+
+		if w.String() != "retry: 3000\n\n" {
+			t.Errorf("Expected the retry field to be sent, got %q", w.String())
+		}
+	})
+
+	t.Run("NotFlushable", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		w := sseNoFlushWriter{ResponseWriter: recorder}
+		req := httptest.NewRequest("GET", "/events", nil)
+
+		if _, err := NewSSE(w, req); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Expected http.ErrNotSupported, got %v", err)
+		}
+
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("Expected the caller to still be able to send status %d, got %d", http.StatusInternalServerError, recorder.Code)
+		}
+	})
+
+	t.Run("UnwrapsWriter", func(t *testing.T) {
+		w := sseUnwrapWriter{ResponseWriter: &sseTestWriter{header: http.Header{}}}
+		req := httptest.NewRequest("GET", "/events", nil)
+
+		stream, err := NewSSE(w, req, WithHeartbeat(0))
+		if err != nil {
+			t.Fatalf("NewSSE failed: %v", err)
+		}
+
+		stream.Close()
+	})
+}
+
+func TestSSESend(t *testing.T) {
+	w := &sseTestWriter{header: http.Header{}}
+	req := httptest.NewRequest("GET", "/events", nil)
+
+	stream, err := NewSSE(w, req, WithHeartbeat(0))
+	if err != nil {
+		t.Fatalf("NewSSE failed: %v", err)
+	}
+
+	events := []SSEEvent{
+		{ID: "1", Event: "progress", Data: "line one\nline two\r\nline three\rline four"},
+		{ID: "2\nevent: injected", Event: "bad\r\nname", Retry: 1500 * time.Millisecond, Data: "x"},
+		{Event: "ping"},
+		{Data: "plain"},
+	}
+
+	for _, event := range events {
+		if err = stream.Send(event); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	if err = stream.SendJson("status", map[string]int{"percent": 50}); err != nil {
+		t.Fatalf("SendJson failed: %v", err)
+	}
+
+	if err = stream.Comment("hello\nworld"); err != nil {
+		t.Fatalf("Comment failed: %v", err)
+	}
+
+	stream.Close()
+
+	expected := "id: 1\nevent: progress\ndata: line one\ndata: line two\ndata: line three\ndata: line four\n\n" +
+		"id: 2event: injected\nevent: badname\nretry: 1500\ndata: x\n\n" +
+		"event: ping\ndata: \n\n" +
+		"data: plain\n\n" +
+		"event: status\ndata: {\"percent\":50}\n\n" +
+		": hello\n: world\n\n"
+
+	if w.String() != expected {
+		t.Errorf("Expected stream\n%q\ngot\n%q", expected, w.String())
+	}
+
+	if w.flushes < len(events) {
+		t.Errorf("Expected at least %d flushes, got %d", len(events), w.flushes)
+	}
+
+	if err = stream.Send(SSEEvent{Data: "late"}); !errors.Is(err, ErrSSEClosed) {
+		t.Errorf("Expected ErrSSEClosed after Close, got %v", err)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	w := &sseTestWriter{header: http.Header{}}
+	req := httptest.NewRequest("GET", "/events", nil)
+
+	stream, err := NewSSE(w, req, WithHeartbeat(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewSSE failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !strings.Contains(w.String(), ": heartbeat\n\n") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a heartbeat")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	stream.Close()
+	written := w.String()
+	time.Sleep(20 * time.Millisecond)
+
+	if w.String() != written {
+		t.Error("Expected no writes after Close returned")
+	}
+}
+
+func TestSSEContextCancelled(t *testing.T) {
+	w := &sseTestWriter{header: http.Header{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+
+	stream, err := NewSSE(w, req, WithHeartbeat(0))
+	if err != nil {
+		t.Fatalf("NewSSE failed: %v", err)
+	}
+
+	defer stream.Close()
+
+	cancel()
+
+	select {
+	case <-stream.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the stream to stop")
+	}
+
+	if err = stream.Send(SSEEvent{Data: "after cancel"}); !errors.Is(err, ErrSSEClosed) {
+		t.Errorf("Expected ErrSSEClosed after cancellation, got %v", err)
+	}
+
+	if strings.Contains(w.String(), "after cancel") {
+		t.Error("Expected nothing to be written after cancellation")
+	}
+}
@@ -0,0 +1,319 @@
+package httphelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+RetryClient is an HttpClient that retries failed requests through another
+HttpClient, waiting longer before each attempt. Requests are retried when
+sending them fails, or when the response has a retryable status, but only
+when the method is idempotent and the body, if any, can be sent again.
+
+Requests refused by an open CircuitBreakerClient, whose errors match
+ErrCircuitOpen, are never retried, since the circuit would refuse them
+again. When retries run out the last response or error is returned,
+exactly as the wrapped client returned it.
+*/
+type RetryClient struct {
+	client HttpClient
+	opts   *RetryOptions
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	random func() float64
+}
+
+/*
+RetryAttempt describes one attempt at sending a request. It is passed to
+the hook set with WithRetryHook.
+*/
+type RetryAttempt struct {
+	Request  *http.Request
+	Attempt  int
+	Response *http.Response
+	Err      error
+	Retrying bool
+	Delay    time.Duration
+}
+
+type RetryOptions struct {
+	MaxAttempts   int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	Deadline      time.Duration
+	RetryStatuses []int
+	Hook          func(attempt RetryAttempt)
+}
+
+type RetryOption func(o *RetryOptions)
+
+/*
+NewRetryClient wraps client so that requests are retried. By default a
+request is sent at most 3 times, waiting 100ms and then 200ms, and is
+retried on network errors and the 429, 502, 503 and 504 statuses.
+
+	client := httphelpers.NewRetryClient(http.DefaultClient,
+		httphelpers.WithMaxAttempts(5),
+		httphelpers.WithRetryDeadline(30*time.Second),
+	)
+*/
+func NewRetryClient(client HttpClient, options ...RetryOption) *RetryClient {
+	return &RetryClient{
+		client: client,
+		opts:   newRetryOptions(options),
+		now:    time.Now,
+		sleep:  sleepContext,
+		random: rand.Float64,
+	}
+}
+
+/*
+WithMaxAttempts sets how many times a request is sent, including the
+first attempt.
+*/
+func WithMaxAttempts(attempts int) RetryOption {
+	return func(o *RetryOptions) {
+		o.MaxAttempts = attempts
+	}
+}
+
+/*
+WithBackoff sets the wait before the first retry, which doubles for each
+retry after it up to maxDelay. Each wait is randomly shortened by up to
+half so that clients that failed together do not retry together.
+*/
+func WithBackoff(base, maxDelay time.Duration) RetryOption {
+	return func(o *RetryOptions) {
+		o.BaseDelay = base
+		o.MaxDelay = maxDelay
+	}
+}
+
+/*
+WithRetryDeadline stops retrying once a retry could not start within d of
+the first attempt. Limit how long each attempt takes with the wrapped
+client, such as with http.Client.Timeout.
+*/
+func WithRetryDeadline(d time.Duration) RetryOption {
+	return func(o *RetryOptions) {
+		o.Deadline = d
+	}
+}
+
+/*
+WithRetryStatuses replaces the response statuses that are retried.
+*/
+func WithRetryStatuses(statuses ...int) RetryOption {
+	return func(o *RetryOptions) {
+		o.RetryStatuses = statuses
+	}
+}
+
+/*
+WithRetryHook calls hook after every attempt, such as to log it.
+*/
+func WithRetryHook(hook func(attempt RetryAttempt)) RetryOption {
+	return func(o *RetryOptions) {
+		o.Hook = hook
+	}
+}
+
+func (c *RetryClient) Do(req *http.Request) (*http.Response, error) {
+	var (
+		err      error
+		response *http.Response
+	)
+
+	start := c.now()
+	retryable := isIdempotent(req) && canReplayBody(req)
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+
+		if attempt > 1 {
+			if attemptReq, err = replayRequest(req); err != nil {
+				return nil, err
+			}
+		}
+
+		response, err = c.client.Do(attemptReq)
+
+		delay, retrying := c.nextDelay(req, attempt, start, response, err)
+		retrying = retrying && retryable
+
+		if c.opts.Hook != nil {
+			c.opts.Hook(RetryAttempt{
+				Request:  attemptReq,
+				Attempt:  attempt,
+				Response: response,
+				Err:      err,
+				Retrying: retrying,
+				Delay:    delay,
+			})
+		}
+
+		if !retrying {
+			return response, err
+		}
+
+		if response != nil {
+			io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+			response.Body.Close()
+		}
+
+		if err = c.sleep(req.Context(), delay); err != nil {
+			return nil, fmt.Errorf("error waiting to retry request: %w", err)
+		}
+	}
+}
+
+/*
+nextDelay decides whether an attempt should be retried, and how long to
+wait first. Requests refused by an open circuit breaker are not retried.
+A Retry-After header takes the place of the backoff, unless it asks for a
+longer wait than the maximum delay.
+*/
+func (c *RetryClient) nextDelay(req *http.Request, attempt int, start time.Time, response *http.Response, err error) (time.Duration, bool) {
+	if attempt >= c.opts.MaxAttempts || req.Context().Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return 0, false
+	}
+
+	if err == nil && !slices.Contains(c.opts.RetryStatuses, response.StatusCode) {
+		return 0, false
+	}
+
+	delay := c.backoff(attempt)
+
+	if response != nil {
+		if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After"), c.now()); ok {
+			if retryAfter > c.opts.MaxDelay {
+				return 0, false
+			}
+
+			delay = retryAfter
+		}
+	}
+
+	if c.opts.Deadline > 0 && c.now().Add(delay).Sub(start) > c.opts.Deadline {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+func (c *RetryClient) backoff(attempt int) time.Duration {
+	delay := c.opts.MaxDelay
+
+	if shift := attempt - 1; shift < 32 && c.opts.BaseDelay<<shift < c.opts.MaxDelay {
+		delay = c.opts.BaseDelay << shift
+	}
+
+	return delay - time.Duration(c.random()*float64(delay/2))
+}
+
+func newRetryOptions(options []RetryOption) *RetryOptions {
+	result := &RetryOptions{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		RetryStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, opt := range options {
+		opt(result)
+	}
+
+	return result
+}
+
+/*
+isIdempotent reports whether a request can safely be sent more than once.
+As with net/http, requests with an Idempotency-Key header are treated as
+idempotent whatever their method.
+*/
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+func canReplayBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+/*
+replayRequest copies req with a fresh body from GetBody, leaving the
+caller's request untouched.
+*/
+func replayRequest(req *http.Request) (*http.Request, error) {
+	result := req.Clone(req.Context())
+
+	if req.GetBody == nil {
+		return result, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("error getting request body to retry: %w", err)
+	}
+
+	result.Body = body
+	return result, nil
+}
+
+/*
+parseRetryAfter reads a Retry-After header, given either as a number of
+seconds or as an HTTP date.
+*/
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(date.Sub(now), 0), true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case <-timer.C:
+		return nil
+	}
+}
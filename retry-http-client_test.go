@@ -0,0 +1,309 @@
+package httphelpers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+newTestRetryClient returns a RetryClient with no jitter and a fake clock
+that advances when it sleeps, along with the delays it slept for.
+*/
+func newTestRetryClient(client HttpClient, options ...RetryOption) (*RetryClient, *[]time.Duration) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	delays := []time.Duration{}
+
+	result := NewRetryClient(client, options...)
+	result.random = func() float64 { return 0 }
+	result.now = func() time.Time { return now }
+
+	result.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		now = now.Add(d)
+		return ctx.Err()
+	}
+
+	return result, &delays
+}
+
+func TestRetryClientRetries(t *testing.T) {
+	networkErr := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		method     string
+		header     http.Header
+		responses  []*MockResponse
+		errs       []error
+		options    []RetryOption
+		wantCalls  int
+		wantStatus int
+		wantDelays []time.Duration
+	}{
+		{
+			name:       "Retries retryable statuses with exponential backoff",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(503), MockStatus(502), MockStatus(200)},
+			wantCalls:  3,
+			wantStatus: 200,
+			wantDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:       "Returns the last response when attempts run out",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(504), MockStatus(504)},
+			options:    []RetryOption{WithMaxAttempts(2)},
+			wantCalls:  2,
+			wantStatus: 504,
+			wantDelays: []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:       "Retries network errors",
+			method:     http.MethodDelete,
+			responses:  []*MockResponse{nil, MockStatus(204)},
+			errs:       []error{networkErr, nil},
+			wantCalls:  2,
+			wantStatus: 204,
+			wantDelays: []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:       "Does not retry other statuses",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(500)},
+			wantCalls:  1,
+			wantStatus: 500,
+			wantDelays: []time.Duration{},
+		},
+		{
+			name:       "Uses configured statuses",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(500), MockStatus(200)},
+			options:    []RetryOption{WithRetryStatuses(500)},
+			wantCalls:  2,
+			wantStatus: 200,
+			wantDelays: []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:       "Does not retry a POST",
+			method:     http.MethodPost,
+			responses:  []*MockResponse{MockStatus(503)},
+			wantCalls:  1,
+			wantStatus: 503,
+			wantDelays: []time.Duration{},
+		},
+		{
+			name:       "Retries a POST with an idempotency key",
+			method:     http.MethodPost,
+			header:     http.Header{"Idempotency-Key": {"abc"}},
+			responses:  []*MockResponse{MockStatus(503), MockStatus(201)},
+			wantCalls:  2,
+			wantStatus: 201,
+			wantDelays: []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:       "Caps the backoff at the maximum delay",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(503), MockStatus(503), MockStatus(503), MockStatus(200)},
+			options:    []RetryOption{WithMaxAttempts(4), WithBackoff(time.Second, 3*time.Second)},
+			wantCalls:  4,
+			wantStatus: 200,
+			wantDelays: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:       "Honors Retry-After in seconds",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(429).WithHeader("Retry-After", "2"), MockStatus(200)},
+			wantCalls:  2,
+			wantStatus: 200,
+			wantDelays: []time.Duration{2 * time.Second},
+		},
+		{
+			name:       "Honors Retry-After as a date",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(503).WithHeader("Retry-After", "Thu, 01 Jan 2026 12:00:05 GMT"), MockStatus(200)},
+			wantCalls:  2,
+			wantStatus: 200,
+			wantDelays: []time.Duration{5 * time.Second},
+		},
+		{
+			name:       "Gives up when Retry-After is longer than the maximum delay",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(429).WithHeader("Retry-After", "3600")},
+			wantCalls:  1,
+			wantStatus: 429,
+			wantDelays: []time.Duration{},
+		},
+		{
+			name:       "Stops at the deadline",
+			method:     http.MethodGet,
+			responses:  []*MockResponse{MockStatus(503), MockStatus(503), MockStatus(503)},
+			options:    []RetryOption{WithMaxAttempts(5), WithBackoff(time.Second, 10*time.Second), WithRetryDeadline(2 * time.Second)},
+			wantCalls:  2,
+			wantStatus: 503,
+			wantDelays: []time.Duration{time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, tb := newRecordingMock(t)
+
+			for index, response := range tt.responses {
+				if response == nil {
+					mock.OnDo(nil, tt.errs[index])
+					continue
+				}
+
+				mock.OnDoRespond(response)
+			}
+
+			client, delays := newTestRetryClient(mock, tt.options...)
+
+			req := httptest.NewRequest(tt.method, "http://example.com/jobs", nil)
+			req.Header = tt.header.Clone()
+
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+
+			response, err := client.Do(req)
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, response.StatusCode)
+			}
+
+			if len(mock.Calls) != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, len(mock.Calls))
+			}
+
+			if len(*delays) != len(tt.wantDelays) {
+				t.Fatalf("Expected delays %v, got %v", tt.wantDelays, *delays)
+			}
+
+			for index, want := range tt.wantDelays {
+				if (*delays)[index] != want {
+					t.Errorf("Expected delays %v, got %v", tt.wantDelays, *delays)
+					break
+				}
+			}
+
+			if len(tb.errors) != 0 {
+				t.Errorf("Unexpected failures %q", tb.errors)
+			}
+		})
+	}
+}
+
+func TestRetryClientReplaysBody(t *testing.T) {
+	mock, tb := newRecordingMock(t)
+	mock.OnDoRespond(MockStatus(http.StatusServiceUnavailable), MatchBody("payload"))
+	mock.OnDoRespond(MockStatus(http.StatusOK), MatchBody("payload"))
+
+	client, _ := newTestRetryClient(mock)
+
+	req, _ := http.NewRequest(http.MethodPut, "http://example.com/items/1", strings.NewReader("payload"))
+	response, err := client.Do(req)
+
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Errorf("Expected the retry to succeed, got %v and %v", response, err)
+	}
+
+	if len(tb.errors) != 0 {
+		t.Errorf("Unexpected failures %q", tb.errors)
+	}
+
+	// Without GetBody the body cannot be sent again, so the request is not retried.
+	mock, _ = newRecordingMock(t)
+	mock.OnDoRespond(MockStatus(http.StatusServiceUnavailable))
+
+	client, _ = newTestRetryClient(mock)
+
+	req, _ = http.NewRequest(http.MethodPut, "http://example.com/items/1", io.NopCloser(strings.NewReader("payload")))
+
+	if response, _ = client.Do(req); response.StatusCode != http.StatusServiceUnavailable || len(mock.Calls) != 1 {
+		t.Errorf("Expected a single attempt, got %d calls", len(mock.Calls))
+	}
+}
+
+func TestRetryClientHook(t *testing.T) {
+	mock, _ := newRecordingMock(t)
+	mock.OnDoRespond(MockStatus(http.StatusBadGateway))
+	mock.OnDoRespond(MockStatus(http.StatusOK))
+
+	attempts := []RetryAttempt{}
+
+	client, _ := newTestRetryClient(mock, WithRetryHook(func(attempt RetryAttempt) {
+		attempts = append(attempts, attempt)
+	}))
+
+	client.Do(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attempts))
+	}
+
+	if attempts[0].Attempt != 1 || !attempts[0].Retrying || attempts[0].Delay != 100*time.Millisecond || attempts[0].Response.StatusCode != http.StatusBadGateway {
+		t.Errorf("Unexpected first attempt %+v", attempts[0])
+	}
+
+	if attempts[1].Attempt != 2 || attempts[1].Retrying || attempts[1].Response.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected second attempt %+v", attempts[1])
+	}
+}
+
+func TestRetryClientContextCanceled(t *testing.T) {
+	mock, _ := newRecordingMock(t)
+	mock.OnDoRespond(MockStatus(http.StatusServiceUnavailable)).AnyTimes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewRetryClient(mock, WithBackoff(time.Hour, time.Hour))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/", nil)
+
+	if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Thu, 01 Jan 2026 12:01:00 GMT", time.Minute, true},
+		{"Thu, 01 Jan 2026 11:00:00 GMT", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Expected %v and %v, got %v and %v", tt.want, tt.wantOk, got, ok)
+			}
+		})
+	}
+}